@@ -0,0 +1,616 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	configurationFile = "client.ini"
+	accountSection    = "account"
+	librarySection    = "library"
+
+	syncModeFull        = "full"
+	syncModeIncremental = "incremental"
+
+	layoutFlat       = "flat"
+	layoutPerLibrary = "per-library"
+	layoutPerId      = "per-id"
+
+	orderServer   = "server"
+	orderLargest  = "largest"
+	orderSmallest = "smallest"
+
+	envUsername = "SEAFILE_USERNAME"
+	envPassword = "SEAFILE_PASSWORD"
+	envUrl      = "SEAFILE_URL"
+	envOutput   = "SEAFILE_OUTPUT"
+)
+
+type Configuration struct {
+	// Name is the name of the [account "name"] section; empty when the file has no account sections
+	Name string
+
+	Username        string
+	Password        string
+	ApiUrl          string
+	OutputDirectory string
+	TempDirectory   string
+	MaxRetries      int
+	RetryDelay      time.Duration
+	Concurrency     int
+	OTP             string
+	Include         []string
+	Exclude         []string
+	SyncMode        string
+	OutputFormat    string
+	Layout          string
+	// ExtractWorkers is the number of files of a library extracted at once; zero means one per CPU, up to 8
+	ExtractWorkers int
+	// CompressionLevel is the level of output_format tar.gz and zip, see seafile.Client.CompressionLevel
+	CompressionLevel int
+	// Manifest writes a SHA256SUMS file with the hashes of the extracted files into every library directory
+	Manifest bool
+	// DownloadOrder is the order in which libraries are downloaded: as listed by the server, or by size
+	DownloadOrder string
+	// RepoTypes are the kinds of libraries to download (mine, shared, group, public); empty means the server default
+	RepoTypes []string
+
+	// RateLimit is the maximum number of requests per second; zero means unlimited
+	RateLimit float64
+	// BandwidthLimit is the maximum download rate of all downloads together, e.g. 2MB/s; empty means unlimited
+	BandwidthLimit string
+	// MaxFileSize skips larger files in incremental mode, e.g. 500MB; empty means no limit
+	MaxFileSize string
+	// FileInclude and FileExclude are patterns of the paths within libraries to download in incremental mode
+	FileInclude []string
+	FileExclude []string
+	// MaxConnections limits the requests in flight, including running downloads, of all libraries together
+	MaxConnections int
+
+	// LibraryPasswords maps the ID or name of encrypted libraries to their password
+	LibraryPasswords map[string]string
+	// LibraryOutputs maps the ID or name of libraries to the output directory they are stored in instead
+	LibraryOutputs map[string]string
+	// Headers are added to every request, e.g. for an authenticating proxy in front of the server
+	Headers map[string]string
+	// UserAgent identifies the client in every request; it defaults to seafile-server-client/<version>
+	UserAgent string
+
+	// Trace logs every HTTP request and response, TraceBodies their bodies as well; set by -trace and -trace-bodies
+	Trace       bool
+	TraceBodies bool
+
+	// Snapshot downloads every run into a new directory named after its start time, below the output directory.
+	// KeepSnapshots (if positive) is how many of them are kept, SnapshotLink maintains a link to the newest one.
+	Snapshot      bool
+	KeepSnapshots int
+	SnapshotLink  bool
+
+	// UploadPolicy is what happens to existing remote files when uploading or restoring: skip, overwrite or rename
+	UploadPolicy string
+
+	// PostDownloadHook is run after every library that was downloaded successfully, PostRunHook after the
+	// whole run; both through the shell
+	PostDownloadHook string
+	PostRunHook      string
+
+	// PasswordFile contains the password; with PasswordSource keyring, the OS keyring is tried first
+	PasswordFile   string
+	PasswordSource string
+
+	LogLevel string
+	// CheckDiskSpace warns before a full download when the output directory has less space than the libraries need
+	CheckDiskSpace bool
+
+	CACert             string
+	InsecureSkipVerify bool
+	// AllowInsecureHTTP permits a url with plain http, over which the password and token are sent unencrypted
+	AllowInsecureHTTP bool
+	// FileServerURL overrides the address of the file server in download and upload links
+	FileServerURL string
+	// Proxy overrides the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	Proxy string
+
+	// Timeout limits a whole API request, DownloadTimeout a whole file transfer; zero disables them
+	Timeout               time.Duration
+	DownloadTimeout       time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// MaxIdleConns and MaxIdleConnsPerHost limit the connections kept open for reuse, altogether (zero means
+	// unlimited) and per host (zero means MaxConnections). IdleConnTimeout closes connections unused that long.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+
+	// readErrs are the values readSection couldn't make sense of, reported by Validate
+	readErrs []error
+}
+
+// uploadPolicies are the values of upload_policy and -upload-policy
+var uploadPolicies = []string{string(seafile.UploadSkip), string(seafile.UploadOverwrite), string(seafile.UploadRename)}
+
+// libraryTypes maps the values of repo_types to the library types of the API
+var libraryTypes = map[string]seafile.LibraryType{
+	"mine":   seafile.LibrariesMine,
+	"shared": seafile.LibrariesShared,
+	"group":  seafile.LibrariesGroup,
+	"public": seafile.LibrariesPublic,
+}
+
+// libraryTypes returns the library types selected by repo_types
+func (c *Configuration) libraryTypes() []seafile.LibraryType {
+	types := make([]seafile.LibraryType, 0, len(c.RepoTypes))
+	for _, repoType := range c.RepoTypes {
+		types = append(types, libraryTypes[repoType])
+	}
+
+	return types
+}
+
+// loadConfigs returns one configuration per [account "name"] section, with the [general] section as default
+// for all of them. Without account sections, the [general] section describes the only account.
+func loadConfigs(configName string) ([]*Configuration, error) {
+	cfg, err := ini.Load(configName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s: %w", configName, err)
+	}
+
+	general := defaultConfiguration()
+	readSection(cfg.Section("general"), general)
+	general.LibraryPasswords = cfg.Section("passwords").KeysHash()
+	general.Headers = cfg.Section("headers").KeysHash()
+	general.LibraryOutputs = make(map[string]string)
+	for _, section := range cfg.Sections() {
+		if name, ok := subsectionName(section.Name(), librarySection); ok && section.HasKey("output") {
+			general.LibraryOutputs[name] = section.Key("output").String()
+		}
+	}
+
+	var configs []*Configuration
+	for _, section := range cfg.Sections() {
+		name, ok := subsectionName(section.Name(), accountSection)
+		if !ok {
+			continue
+		}
+
+		c := *general
+		c.Name = name
+		// Appending to the errors of [general] would share them between the accounts
+		c.readErrs = slices.Clone(general.readErrs)
+		if !section.HasKey("output") {
+			// Accounts must not download into the same directory
+			c.OutputDirectory = filepath.Join(general.OutputDirectory, name)
+		}
+		readSection(section, &c)
+		// Runs once for all accounts
+		c.PostRunHook = general.PostRunHook
+		configs = append(configs, &c)
+	}
+
+	if len(configs) == 0 {
+		configs = append(configs, general)
+	}
+
+	return configs, nil
+}
+
+// subsectionName returns the name of a [kind "name"] section, e.g. of an account
+func subsectionName(section, kind string) (string, bool) {
+	if !strings.HasPrefix(section, kind+" ") {
+		return "", false
+	}
+
+	name := strings.Trim(strings.TrimPrefix(section, kind+" "), "\" ")
+	return name, len(name) > 0
+}
+
+// readSection overwrites c with the keys set in section. Missing keys keep their current value, so
+// environment variables can still fill in those that are empty.
+func readSection(section *ini.Section, c *Configuration) {
+	c.Username = section.Key("username").MustString(c.Username)
+	c.Password = section.Key("password").MustString(c.Password)
+	c.PasswordFile = section.Key("password_file").MustString(c.PasswordFile)
+	c.PasswordSource = section.Key("password_source").MustString(c.PasswordSource)
+	c.ApiUrl = section.Key("url").MustString(c.ApiUrl)
+	c.OutputDirectory = section.Key("output").MustString(c.OutputDirectory)
+	c.TempDirectory = section.Key("temp").MustString(c.TempDirectory)
+	readValue(c, section, "retries", &c.MaxRetries, (*ini.Key).Int, "a whole number")
+	readValue(c, section, "retry_delay", &c.RetryDelay, (*ini.Key).Duration, "a duration such as 30s or 5m")
+	readValue(c, section, "concurrency", &c.Concurrency, (*ini.Key).Int, "a whole number")
+	readValue(c, section, "rate_limit", &c.RateLimit, (*ini.Key).Float64, "a number")
+	readValue(c, section, "max_connections", &c.MaxConnections, (*ini.Key).Int, "a whole number")
+	c.BandwidthLimit = section.Key("bandwidth_limit").MustString(c.BandwidthLimit)
+	c.MaxFileSize = section.Key("max_file_size").MustString(c.MaxFileSize)
+	c.PostDownloadHook = section.Key("post_download_hook").MustString(c.PostDownloadHook)
+	c.PostRunHook = section.Key("post_run_hook").MustString(c.PostRunHook)
+	c.OTP = section.Key("otp").MustString(c.OTP)
+	if section.HasKey("include") {
+		c.Include = splitList(section.Key("include").String())
+	}
+	if section.HasKey("exclude") {
+		c.Exclude = splitList(section.Key("exclude").String())
+	}
+	if section.HasKey("file_include") {
+		c.FileInclude = splitList(section.Key("file_include").String())
+	}
+	if section.HasKey("file_exclude") {
+		c.FileExclude = splitList(section.Key("file_exclude").String())
+	}
+	if section.HasKey("repo_types") {
+		c.RepoTypes = splitList(section.Key("repo_types").String())
+	}
+	c.OutputFormat = section.Key("output_format").MustString(c.OutputFormat)
+	c.Layout = section.Key("layout").MustString(c.Layout)
+	c.UploadPolicy = section.Key("upload_policy").MustString(c.UploadPolicy)
+	c.DownloadOrder = section.Key("download_order").MustString(c.DownloadOrder)
+	c.SyncMode = section.Key("sync_mode").MustString(c.SyncMode)
+	readValue(c, section, "extract_workers", &c.ExtractWorkers, (*ini.Key).Int, "a whole number")
+	readValue(c, section, "compression_level", &c.CompressionLevel, (*ini.Key).Int, "a whole number")
+	readValue(c, section, "manifest", &c.Manifest, (*ini.Key).Bool, "true or false")
+	readValue(c, section, "check_disk_space", &c.CheckDiskSpace, (*ini.Key).Bool, "true or false")
+	readValue(c, section, "snapshot", &c.Snapshot, (*ini.Key).Bool, "true or false")
+	readValue(c, section, "keep_snapshots", &c.KeepSnapshots, (*ini.Key).Int, "a whole number")
+	readValue(c, section, "snapshot_link", &c.SnapshotLink, (*ini.Key).Bool, "true or false")
+	c.LogLevel = section.Key("log_level").MustString(c.LogLevel)
+	c.CACert = section.Key("ca_cert").MustString(c.CACert)
+	readValue(c, section, "insecure_skip_verify", &c.InsecureSkipVerify, (*ini.Key).Bool, "true or false")
+	readValue(c, section, "allow_insecure_http", &c.AllowInsecureHTTP, (*ini.Key).Bool, "true or false")
+	c.Proxy = section.Key("proxy").MustString(c.Proxy)
+	c.UserAgent = section.Key("user_agent").MustString(c.UserAgent)
+	c.FileServerURL = section.Key("fileserver_url").MustString(c.FileServerURL)
+	readValue(c, section, "timeout", &c.Timeout, (*ini.Key).Duration, "a duration such as 30s or 5m")
+	readValue(c, section, "download_timeout", &c.DownloadTimeout, (*ini.Key).Duration, "a duration such as 30s or 5m")
+	readValue(c, section, "dial_timeout", &c.DialTimeout, (*ini.Key).Duration, "a duration such as 30s or 5m")
+	readValue(c, section, "tls_handshake_timeout", &c.TLSHandshakeTimeout, (*ini.Key).Duration, "a duration such as 30s or 5m")
+	readValue(c, section, "response_header_timeout", &c.ResponseHeaderTimeout, (*ini.Key).Duration, "a duration such as 30s or 5m")
+	readValue(c, section, "max_idle_conns", &c.MaxIdleConns, (*ini.Key).Int, "a whole number")
+	readValue(c, section, "max_idle_conns_per_host", &c.MaxIdleConnsPerHost, (*ini.Key).Int, "a whole number")
+	readValue(c, section, "idle_conn_timeout", &c.IdleConnTimeout, (*ini.Key).Duration, "a duration such as 30s or 5m")
+}
+
+// readValue sets value to the value of key as parsed by parse, keeping it when the key is missing or empty. A
+// value that can't be parsed is kept for Validate to report, rather than silently replaced by the default.
+func readValue[T any](c *Configuration, section *ini.Section, key string, value *T, parse func(*ini.Key) (T, error), expected string) {
+	if len(strings.TrimSpace(section.Key(key).String())) == 0 {
+		return
+	}
+
+	parsed, err := parse(section.Key(key))
+	if err != nil {
+		c.readErrs = append(c.readErrs, fmt.Errorf("invalid %q %q in the [%s] section: expected %s",
+			key, section.Key(key).String(), section.Name(), expected))
+		return
+	}
+	*value = parsed
+}
+
+// selectAccount returns the configuration of the named account, or all of them when name is empty
+func selectAccount(configs []*Configuration, name string) ([]*Configuration, error) {
+	if len(name) == 0 {
+		return configs, nil
+	}
+
+	for _, c := range configs {
+		if c.Name == name {
+			return []*Configuration{c}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no [%s %q] section in the configuration file", accountSection, name)
+}
+
+// defaultConfiguration returns the settings used for anything that isn't configured explicitly.
+// An empty TempDirectory makes ioutil.TempFile fall back to os.TempDir.
+func defaultConfiguration() *Configuration {
+	return &Configuration{
+		OutputDirectory: "data",
+		MaxRetries:      3,
+		RetryDelay:      time.Second,
+		Concurrency:     4,
+		RateLimit:       5,
+		MaxConnections:  8,
+		SyncMode:        syncModeFull,
+		OutputFormat:    string(seafile.FormatFiles),
+		Layout:          layoutPerLibrary,
+		DownloadOrder:   orderServer,
+		PasswordSource:  passwordSourceConfig,
+		UploadPolicy:    string(seafile.UploadSkip),
+		LogLevel:        "info",
+		CheckDiskSpace:  true,
+		UserAgent:       "seafile-server-client/" + version,
+		SnapshotLink:    true,
+
+		CompressionLevel: seafile.DefaultCompression,
+
+		Timeout:               time.Minute,
+		DialTimeout:           10 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Minute,
+
+		MaxIdleConns:    100,
+		IdleConnTimeout: 90 * time.Second,
+	}
+}
+
+// applyEnvOverrides overwrites configuration values with those set in the environment.
+// Environment variables take precedence over the configuration file. With several accounts, each
+// downloads into a subdirectory of SEAFILE_OUTPUT named after it, like with -output.
+func applyEnvOverrides(c *Configuration, multipleAccounts bool) {
+	overrides := []struct {
+		env   string
+		value *string
+	}{
+		{envUsername, &c.Username},
+		{envPassword, &c.Password},
+		{envUrl, &c.ApiUrl},
+		{envOutput, &c.OutputDirectory},
+	}
+
+	for _, override := range overrides {
+		if value, ok := os.LookupEnv(override.env); ok && len(value) > 0 {
+			*override.value = value
+		}
+	}
+
+	if output := os.Getenv(envOutput); len(output) > 0 && multipleAccounts {
+		c.OutputDirectory = filepath.Join(output, c.Name)
+	}
+}
+
+// Validate checks the settings that can't be used as they are and normalizes the API URL. All problems
+// are reported at once, so they can be fixed in one go.
+func (c *Configuration) Validate() error {
+	errs := slices.Clone(c.readErrs)
+
+	required := []struct {
+		key, env, value string
+	}{
+		{"username", envUsername, c.Username},
+		{"password", envPassword, c.Password},
+		{"url", envUrl, c.ApiUrl},
+	}
+
+	section := "[general]"
+	if len(c.Name) > 0 {
+		section = fmt.Sprintf("[%s %q]", accountSection, c.Name)
+	}
+
+	for _, r := range required {
+		if len(strings.TrimSpace(r.value)) == 0 {
+			errs = append(errs, fmt.Errorf("missing %q: set it in the %s section of the configuration file or via %s", r.key, section, r.env))
+		}
+	}
+
+	if len(c.ApiUrl) > 0 {
+		if err := validateApiUrl(c.ApiUrl); err != nil {
+			errs = append(errs, err)
+		} else if c.ApiUrl, err = seafile.NormalizeBaseURL(c.ApiUrl); err != nil {
+			errs = append(errs, err)
+		} else if c.insecureHTTP() && !c.AllowInsecureHTTP {
+			// A missing "s" would otherwise send the password in cleartext without anyone noticing
+			errs = append(errs, fmt.Errorf("\"url\" %s uses plain http, which sends the password unencrypted: use https, or set "+
+				"\"allow_insecure_http\" = true (or -allow-insecure-http) if that is really intended", c.ApiUrl))
+		}
+	}
+
+	if len(c.FileServerURL) > 0 {
+		if u, err := url.Parse(c.FileServerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			errs = append(errs, fmt.Errorf("invalid \"fileserver_url\" %q: expected e.g. https://seafile.example.com/seafhttp", c.FileServerURL))
+		}
+	}
+
+	// Checked here rather than when reading the file, where a typo would silently fall back to the default
+	choices := []struct {
+		key, value string
+		allowed    []string
+	}{
+		{"password_source", c.PasswordSource, []string{passwordSourceConfig, passwordSourceKeyring}},
+		{"sync_mode", c.SyncMode, []string{syncModeFull, syncModeIncremental}},
+		{"output_format", c.OutputFormat, []string{string(seafile.FormatFiles), string(seafile.FormatTarGz), string(seafile.FormatZip)}},
+		{"layout", c.Layout, []string{layoutPerLibrary, layoutPerId, layoutFlat}},
+		{"upload_policy", c.UploadPolicy, uploadPolicies},
+		{"download_order", c.DownloadOrder, []string{orderServer, orderLargest, orderSmallest}},
+		{"log_level", c.LogLevel, []string{"debug", "info", "warn", "error"}},
+	}
+	for _, choice := range choices {
+		if !slices.Contains(choice.allowed, choice.value) {
+			errs = append(errs, fmt.Errorf("invalid %q %q: expected one of %s", choice.key, choice.value, strings.Join(choice.allowed, ", ")))
+		}
+	}
+
+	if c.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("invalid \"concurrency\" %d: at least one library has to be downloaded at a time", c.Concurrency))
+	}
+
+	if c.SyncMode == syncModeIncremental && c.OutputFormat != string(seafile.FormatFiles) {
+		errs = append(errs, fmt.Errorf("\"sync_mode\" %s only works with \"output_format\" %s", syncModeIncremental, seafile.FormatFiles))
+	}
+
+	if c.CompressionLevel < seafile.DefaultCompression || c.CompressionLevel > seafile.BestCompression {
+		errs = append(errs, fmt.Errorf("invalid \"compression_level\" %d: expected 0 (store only) to 9, or -1 for the default", c.CompressionLevel))
+	} else if c.CompressionLevel != seafile.DefaultCompression && c.OutputFormat == string(seafile.FormatFiles) {
+		errs = append(errs, fmt.Errorf("\"compression_level\" needs \"output_format\" %s or %s", seafile.FormatTarGz, seafile.FormatZip))
+	}
+
+	for _, repoType := range c.RepoTypes {
+		if _, ok := libraryTypes[repoType]; !ok {
+			errs = append(errs, fmt.Errorf("invalid \"repo_types\" entry %q: expected mine, shared, group or public", repoType))
+		}
+	}
+
+	if _, err := parseBandwidth(c.BandwidthLimit); err != nil {
+		errs = append(errs, err)
+	}
+
+	// A full download is a single zip of the whole library, which can't leave out files
+	if maxSize, err := c.maxFileSize(); err != nil {
+		errs = append(errs, err)
+	} else if maxSize > 0 && c.SyncMode != syncModeIncremental {
+		errs = append(errs, fmt.Errorf("\"max_file_size\" needs \"sync_mode\" %s, a full download can't skip files", syncModeIncremental))
+	}
+
+	for _, pattern := range append(append([]string{}, c.FileInclude...), c.FileExclude...) {
+		if err := seafile.CheckPattern(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid pattern %q in \"file_include\" or \"file_exclude\": %w", pattern, err))
+		}
+	}
+	if (len(c.FileInclude) > 0 || len(c.FileExclude) > 0) && c.SyncMode != syncModeIncremental {
+		errs = append(errs, fmt.Errorf("\"file_include\" and \"file_exclude\" need \"sync_mode\" %s, a full download can't skip files", syncModeIncremental))
+	}
+
+	if c.MaxConnections < 1 {
+		errs = append(errs, fmt.Errorf("invalid \"max_connections\" %d: at least one connection is needed", c.MaxConnections))
+	}
+
+	for name := range c.Headers {
+		if len(name) == 0 || strings.ContainsAny(name, " \t:") {
+			errs = append(errs, fmt.Errorf("invalid header name %q in [headers]", name))
+		}
+	}
+
+	if strings.ContainsAny(c.UserAgent, "\r\n") {
+		errs = append(errs, errors.New("invalid \"user_agent\": it has to fit on a single line"))
+	}
+
+	if c.MaxIdleConns < 0 || c.MaxIdleConnsPerHost < 0 || c.IdleConnTimeout < 0 {
+		errs = append(errs, errors.New("\"max_idle_conns\", \"max_idle_conns_per_host\" and \"idle_conn_timeout\" can't be negative"))
+	}
+
+	if c.Manifest && (c.SyncMode != syncModeFull || c.OutputFormat != string(seafile.FormatFiles) || c.Layout == layoutFlat) {
+		errs = append(errs, fmt.Errorf("\"manifest\" needs \"sync_mode\" %s, \"output_format\" %s and a layout other than %s",
+			syncModeFull, seafile.FormatFiles, layoutFlat))
+	}
+
+	if c.KeepSnapshots < 0 {
+		errs = append(errs, fmt.Errorf("invalid \"keep_snapshots\" %d: can't be negative", c.KeepSnapshots))
+	} else if c.KeepSnapshots > 0 && !c.Snapshot {
+		errs = append(errs, errors.New("\"keep_snapshots\" needs \"snapshot\" = true"))
+	}
+
+	if c.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("invalid \"rate_limit\" %g: can't be negative", c.RateLimit))
+	}
+
+	if c.ExtractWorkers < 0 {
+		errs = append(errs, fmt.Errorf("invalid \"extract_workers\" %d: can't be negative", c.ExtractWorkers))
+	}
+
+	if c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid \"retries\" %d: can't be negative", c.MaxRetries))
+	}
+
+	return errors.Join(errs...)
+}
+
+// insecureHTTP reports whether the API is accessed over plain http
+func (c *Configuration) insecureHTTP() bool {
+	u, err := url.Parse(c.ApiUrl)
+	return err == nil && strings.EqualFold(u.Scheme, "http")
+}
+
+func validateApiUrl(value string) error {
+	// Without a scheme, url.Parse takes the host name for a path, so point at the likely cause directly
+	if !strings.Contains(value, "://") {
+		return fmt.Errorf("invalid \"url\" %q: missing scheme, did you mean https://%s?", value, value)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid \"url\" %q: %v", value, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid \"url\" %q: scheme must be http or https", value)
+	}
+
+	if len(u.Host) == 0 {
+		return fmt.Errorf("invalid \"url\" %q: missing host name", value)
+	}
+
+	return nil
+}
+
+// sizeUnits are the units parseSize accepts, in bytes
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "m": 1e6, "mb": 1e6, "g": 1e9, "gb": 1e9,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30,
+}
+
+// parseBandwidth parses a rate such as 2MB/s, 500 KiB/s or 100000 (bytes per second) into bytes per second.
+// An empty value or 0 means unlimited.
+func parseBandwidth(value string) (float64, error) {
+	bytes, ok := parseSize(strings.TrimSuffix(strings.TrimSpace(value), "/s"))
+	if !ok {
+		return 0, fmt.Errorf("invalid \"bandwidth_limit\" %q: expected e.g. 500KB/s or 2MB/s", value)
+	}
+
+	return bytes, nil
+}
+
+// parseSize parses a size such as 500MB, 1.5 GiB or 100000 (bytes) into bytes; an empty value is 0
+func parseSize(value string) (float64, bool) {
+	spec := strings.TrimSpace(value)
+	if len(spec) == 0 {
+		return 0, true
+	}
+
+	unitStart := strings.IndexFunc(spec, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if unitStart < 0 {
+		unitStart = len(spec)
+	}
+
+	number, err := strconv.ParseFloat(spec[:unitStart], 64)
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(spec[unitStart:]))]
+	if err != nil || !ok || number < 0 {
+		return 0, false
+	}
+
+	return number * unit, true
+}
+
+// maxFileSize returns max_file_size in bytes; zero means no limit
+func (c *Configuration) maxFileSize() (int64, error) {
+	bytes, ok := parseSize(c.MaxFileSize)
+	if !ok {
+		return 0, fmt.Errorf("invalid \"max_file_size\" %q: expected e.g. 500MB or 2GiB", c.MaxFileSize)
+	}
+
+	return int64(bytes), nil
+}
+
+// libraryOutput returns the output directory of the library: the one of its [library "name"] section, looked
+// up by ID first, or the output directory of the account
+func libraryOutput(c *Configuration, library seafile.Library) string {
+	if output, ok := c.LibraryOutputs[library.Id]; ok {
+		return output
+	}
+	if output, ok := c.LibraryOutputs[library.Name]; ok {
+		return output
+	}
+
+	return c.OutputDirectory
+}
+
+// libraryPassword looks up the password for an encrypted library by ID first, then by name
+func libraryPassword(c *Configuration, library seafile.Library) (string, bool) {
+	if password, ok := c.LibraryPasswords[library.Id]; ok {
+		return password, true
+	}
+
+	password, ok := c.LibraryPasswords[library.Name]
+	return password, ok
+}
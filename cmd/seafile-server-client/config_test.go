@@ -0,0 +1,147 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestValidateApiUrl(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr string
+	}{
+		{"https://seafile.example.com/api2", ""},
+		{"https://seafile.example.com/api2/", ""},
+		{"http://localhost:8000", ""},
+		{"seafile.example.com", "missing scheme, did you mean https://seafile.example.com?"},
+		{"seafile.example.com/api2/", "missing scheme"},
+		{"ftp://seafile.example.com", "scheme must be http or https"},
+		{"https://", "missing host name"},
+	}
+
+	for _, test := range tests {
+		err := validateApiUrl(test.url)
+		if len(test.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("validateApiUrl(%q): unexpected error %v", test.url, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("validateApiUrl(%q) = %v, want an error containing %q", test.url, err, test.wantErr)
+		}
+	}
+}
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		ok    bool
+	}{
+		{"", 0, true},
+		{"2MB/s", 2 * 1000 * 1000, true},
+		{"500 KB/s", 500 * 1000, true},
+		{"1.5MiB/s", 1.5 * 1024 * 1024, true},
+		{"100000", 100000, true},
+		{"fast", 0, false},
+		{"-1MB/s", 0, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseBandwidth(test.value)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseBandwidth(%q) = %v, %v; want %v, ok: %v", test.value, got, err, test.want, test.ok)
+		}
+	}
+}
+
+func TestApplyEnvOverridesOutput(t *testing.T) {
+	t.Setenv(envOutput, "/backup")
+
+	tests := []struct {
+		name             string
+		multipleAccounts bool
+		want             string
+	}{
+		{"single account", false, "/backup"},
+		{"several accounts", true, filepath.Join("/backup", "work")},
+	}
+
+	for _, test := range tests {
+		c := &Configuration{Name: "work", OutputDirectory: "data"}
+		applyEnvOverrides(c, test.multipleAccounts)
+		if c.OutputDirectory != test.want {
+			t.Errorf("%s: output is %q, want %q", test.name, c.OutputDirectory, test.want)
+		}
+	}
+}
+
+func TestValidateRejectsUnknownChoices(t *testing.T) {
+	tests := []struct {
+		settings string
+		wantErr  string
+	}{
+		{"sync_mode = incremental", ""},
+		{"sync_mode = incremantal", `invalid "sync_mode" "incremantal": expected one of full, incremental`},
+		{"output_format = tgz", `invalid "output_format" "tgz": expected one of files, tar.gz, zip`},
+		{"layout = per_library", `invalid "layout" "per_library": expected one of per-library, per-id, flat`},
+		{"password_source = keychain", `invalid "password_source" "keychain": expected one of config, keyring`},
+	}
+
+	for _, test := range tests {
+		file, err := ini.Load([]byte("username = user\npassword = secret\nurl = https://seafile.example.com\n" + test.settings))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := defaultConfiguration()
+		readSection(file.Section(ini.DefaultSection), c)
+
+		err = c.Validate()
+		if len(test.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.settings, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got %v, want an error containing %s", test.settings, err, test.wantErr)
+		}
+	}
+}
+
+func TestValidateReportsUnparsableValues(t *testing.T) {
+	tests := []struct {
+		settings string
+		wantErr  string
+	}{
+		{"timeout = 30s\nconcurrency = 2\nmanifest = true", ""},
+		{"timeout = 30", `invalid "timeout" "30" in the [general] section: expected a duration such as 30s or 5m`},
+		{"concurrency = four", `invalid "concurrency" "four" in the [general] section: expected a whole number`},
+		{"manifest = ture", `invalid "manifest" "ture" in the [general] section: expected true or false`},
+		{"rate_limit = fast", `invalid "rate_limit" "fast" in the [general] section: expected a number`},
+	}
+
+	for _, test := range tests {
+		file, err := ini.Load([]byte("[general]\nusername = user\npassword = secret\nurl = https://seafile.example.com\n" + test.settings))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := defaultConfiguration()
+		readSection(file.Section("general"), c)
+
+		err = c.Validate()
+		if len(test.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", test.settings, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%q: got %v, want an error containing %s", test.settings, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"log/slog"
+)
+
+// checkDiskSpace warns when the output directory has less free space than needed, the total size of the
+// libraries about to be downloaded. It only warns: existing files may be overwritten, and archives are
+// compressed.
+func checkDiskSpace(outputDir string, needed int64) {
+	free, ok := freeSpace(outputDir)
+	if !ok {
+		return
+	}
+
+	if free < needed {
+		slog.Warn("The output directory may not have enough free space for all libraries",
+			"free", formatBytes(free), "needed", formatBytes(needed), "output", outputDir)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// freeSpace is not supported on this platform, so the disk space check is skipped
+func freeSpace(path string) (int64, bool) {
+	return 0, false
+}
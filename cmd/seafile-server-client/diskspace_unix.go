@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// freeSpace returns the number of bytes available to unprivileged users on the filesystem of path
+func freeSpace(path string) (int64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), true
+}
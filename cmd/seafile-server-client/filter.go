@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// filterLibraries keeps the libraries matching include (all of them when include is empty), minus those
+// matching exclude. Exclude wins when a library matches both.
+func filterLibraries(libraries []seafile.Library, include, exclude []string) []seafile.Library {
+	var filtered []seafile.Library
+
+	for _, library := range libraries {
+		if pattern, ok := matchLibrary(library, exclude); ok {
+			slog.Info("Skipping library, it matches an exclude pattern", "library", library.Name, "id", library.Id, "pattern", pattern)
+			continue
+		}
+
+		if len(include) > 0 {
+			if _, ok := matchLibrary(library, include); !ok {
+				slog.Info("Skipping library, it does not match any include pattern", "library", library.Name, "id", library.Id)
+				continue
+			}
+		}
+
+		filtered = append(filtered, library)
+	}
+
+	return filtered
+}
+
+// filterModifiedSince keeps the libraries modified at or after since. Libraries of which the server
+// doesn't report a modification time are kept, as they may have changed.
+func filterModifiedSince(libraries []seafile.Library, since time.Time) []seafile.Library {
+	var filtered []seafile.Library
+
+	for _, library := range libraries {
+		if modified := library.ModTime(); !modified.IsZero() && modified.Before(since) {
+			slog.Info("Skipping library, it wasn't modified recently", "library", library.Name, "id", library.Id,
+				"modified", modified.Format(time.RFC3339))
+			continue
+		}
+
+		filtered = append(filtered, library)
+	}
+
+	return filtered
+}
+
+// parseSince parses the window of -since: a Go duration such as 12h, or a number of days such as 7d
+func parseSince(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err == nil && n > 0 {
+			return time.Duration(n * float64(24*time.Hour)), nil
+		}
+	} else if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, nil
+	}
+
+	return 0, fmt.Errorf("invalid -since %q, expected a duration such as 12h or a number of days such as 7d", value)
+}
+
+// matchLibrary returns the first pattern that matches either the name or the ID of the library
+func matchLibrary(library seafile.Library, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		if globMatch(pattern, library.Name) || globMatch(pattern, library.Id) {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+func globMatch(pattern, value string) bool {
+	matched, err := filepath.Match(pattern, value)
+	if err != nil {
+		// Treat malformed patterns as literal names
+		return pattern == value
+	}
+
+	return matched
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
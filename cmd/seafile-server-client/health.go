@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// checkReporter writes the outcome of a step of a check and reports whether it passed
+type checkReporter func(step string, err error) bool
+
+// checkHealth pings the server, authenticates and pings again with the token, writing OK or FAIL for
+// every step. A failing step skips the steps depending on it. It reports whether all steps passed.
+func checkHealth(ctx context.Context, w io.Writer, c *Configuration, opts runOptions, showAccount bool) bool {
+	prefix := ""
+	if showAccount {
+		prefix = c.Name + ": "
+	}
+
+	report := func(step string, err error) bool {
+		if err != nil {
+			fmt.Fprintf(w, "%s%s FAIL: %v\n", prefix, step, err)
+			return false
+		}
+
+		fmt.Fprintf(w, "%s%s OK\n", prefix, step)
+		return true
+	}
+
+	return runChecks(ctx, c, opts, report)
+}
+
+// checkConfig is checkHealth for -check: it starts with the outcome of validating the configuration
+// (invalid), colors the outcomes on a terminal and suggests a fix for every failure
+func checkConfig(ctx context.Context, w io.Writer, c *Configuration, invalid error, opts runOptions, showAccount bool) bool {
+	prefix := ""
+	if showAccount {
+		prefix = c.Name + ": "
+	}
+
+	pass, fail := "OK", "FAIL"
+	if file, ok := w.(*os.File); ok && isTerminal(file) && len(os.Getenv("NO_COLOR")) == 0 {
+		pass, fail = "\033[32mOK\033[0m", "\033[31mFAIL\033[0m"
+	}
+
+	report := func(step string, err error) bool {
+		if err != nil {
+			fmt.Fprintf(w, "%s%-13s %s: %v\n", prefix, step, fail, err)
+			if hint := checkHint(step, err); len(hint) > 0 {
+				fmt.Fprintf(w, "%s%-13s %s\n", prefix, "", hint)
+			}
+			return false
+		}
+
+		fmt.Fprintf(w, "%s%-13s %s\n", prefix, step, pass)
+		return true
+	}
+
+	if !report("configuration", invalid) {
+		return false
+	}
+
+	return runChecks(ctx, c, opts, report)
+}
+
+// runChecks runs the steps of checkHealth, stopping at the first that fails
+func runChecks(ctx context.Context, c *Configuration, opts runOptions, report checkReporter) bool {
+	// No progress output for a run that transfers nothing
+	opts.Quiet = true
+	client, err := newClient(c, opts)
+	if err != nil {
+		return report("setup", err)
+	}
+
+	if !report("ping", client.Ping(ctx)) {
+		return false
+	}
+
+	// A fresh token, so the credentials themselves are checked rather than a cached token
+	if !report("token", authenticate(ctx, client, c, opts.OTP)) {
+		return false
+	}
+
+	return report("auth ping", client.AuthPing(ctx))
+}
+
+// checkHint suggests how to fix the failure of a step of checkConfig; empty when there's nothing to add
+func checkHint(step string, err error) string {
+	var (
+		apiErr       *seafile.APIError
+		dnsErr       *net.DNSError
+		netErr       net.Error
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+	)
+
+	switch {
+	case step == "configuration":
+		// The validation errors say which keys to fix already
+		return ""
+	case errors.Is(err, seafile.ErrNotSeafile):
+		return "Set \"url\" to the address of the Seafile server as opened in the browser, e.g. https://seafile.example.com; /api2 is appended"
+	case errors.As(err, &dnsErr):
+		return "Check the host name in \"url\"; it doesn't resolve"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "Nothing listens at \"url\": check the host and port, and that the server is running"
+	case errors.As(err, &authorityErr):
+		return "The server certificate is signed by an unknown CA: point \"ca_cert\" at its certificate"
+	case errors.As(err, &hostnameErr):
+		return "The server certificate doesn't match the host name in \"url\""
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "The server didn't answer in time: check \"proxy\", firewalls, and \"dial_timeout\""
+	case errors.Is(err, seafile.ErrOTPRequired):
+		return "The account uses two-factor authentication: pass -otp or set \"otp\""
+	case errors.Is(err, seafile.ErrInvalidCredentials):
+		return "Check \"username\" (usually the email address) and the password; the account may also be locked or inactive"
+	case errors.Is(err, seafile.ErrUnauthorized):
+		return "The server refused a fresh token; a proxy in front of it may drop the Authorization header"
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		return "There's no Seafile API at \"url\": if Seafile runs below a path (e.g. /seafile), include it, without /api2"
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+		return "Something in front of the server refuses access; an authenticating proxy may need a [headers] section"
+	}
+
+	return ""
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// runHook runs command through the shell, with env (as KEY=value) added to the environment, and logs what it
+// writes. The hook is named after its configuration key in the log; a non-zero exit status is returned as error.
+func runHook(ctx context.Context, name, command string, env []string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+
+	output, err := cmd.CombinedOutput()
+	if output = bytes.TrimSpace(output); len(output) > 0 {
+		slog.Info("Hook output", "hook", name, "output", string(output))
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+
+	return nil
+}
+
+// libraryHookEnv describes a downloaded library to post_download_hook
+func libraryHookEnv(c *Configuration, library seafile.Library, libraryDir string) []string {
+	output := libraryDir
+	switch seafile.OutputFormat(c.OutputFormat) {
+	case seafile.FormatTarGz:
+		output += ".tar.gz"
+	case seafile.FormatZip:
+		output += ".zip"
+	}
+
+	return []string{
+		"SEAFILE_ACCOUNT=" + c.Name,
+		"SEAFILE_LIBRARY_ID=" + library.Id,
+		"SEAFILE_LIBRARY_NAME=" + library.Name,
+		"SEAFILE_LIBRARY_PATH=" + output,
+	}
+}
+
+// runHookEnv summarizes the run for post_run_hook
+func runHookEnv(succeeded, failed, skipped, empty int, reportPath string) []string {
+	return []string{
+		"SEAFILE_SUCCEEDED=" + strconv.Itoa(succeeded),
+		"SEAFILE_FAILED=" + strconv.Itoa(failed),
+		"SEAFILE_UNCHANGED=" + strconv.Itoa(skipped),
+		"SEAFILE_EMPTY=" + strconv.Itoa(empty),
+		"SEAFILE_REPORT=" + reportPath,
+	}
+}
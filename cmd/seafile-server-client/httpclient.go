@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxRedirects is the number of redirects followed per request, like the default of net/http
+const maxRedirects = 10
+
+// newHTTPClients builds the clients used for API requests and for file transfers, honoring the TLS and
+// timeout settings of the configuration. Both share a transport, so connections are reused.
+func newHTTPClients(c *Configuration) (api, transfer *http.Client, err error) {
+	tlsConfig := &tls.Config{}
+
+	if len(c.CACert) > 0 {
+		pem, err := ioutil.ReadFile(c.CACert)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read CA certificate: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no valid PEM certificates found in %s", c.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.InsecureSkipVerify {
+		slog.Warn("TLS certificate verification is DISABLED (insecure_skip_verify); " +
+			"the connection to the server can be intercepted")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DialContext = (&net.Dialer{Timeout: c.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = c.ResponseHeaderTimeout
+	// Parallel downloads from the file server can all reuse their connection, instead of only the two
+	// connections per host net/http keeps by default
+	transport.MaxIdleConns = c.MaxIdleConns
+	transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	if transport.MaxIdleConnsPerHost == 0 {
+		transport.MaxIdleConnsPerHost = c.MaxConnections
+	}
+	transport.IdleConnTimeout = c.IdleConnTimeout
+
+	if err = configureProxy(transport, c); err != nil {
+		return nil, nil, err
+	}
+
+	var roundTripper http.RoundTripper = transport
+	// Inside the custom headers, so the headers that are actually sent are logged
+	if c.Trace {
+		roundTripper = &traceTransport{next: roundTripper, bodies: c.TraceBodies}
+	}
+	roundTripper = &headerTransport{next: roundTripper, headers: requestHeaders(c), hosts: headerHosts(c)}
+
+	api = &http.Client{Transport: roundTripper, Timeout: c.Timeout, CheckRedirect: checkRedirect}
+	transfer = &http.Client{Transport: roundTripper, Timeout: c.DownloadTimeout, CheckRedirect: checkRedirect}
+	return api, transfer, nil
+}
+
+// headerTransport adds the configured headers to every request to one of the hosts, also to those following a
+// redirect. They may hold secrets, e.g. for an authenticating proxy, so like the auth token they aren't sent to
+// other hosts; only the User-Agent is. Headers the request sets itself, like Authorization, take precedence.
+type headerTransport struct {
+	next    http.RoundTripper
+	headers map[string]string
+	// hosts are the lowercase hosts (and ports) the headers are meant for
+	hosts map[string]bool
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	knownHost := t.hosts[strings.ToLower(req.URL.Host)]
+	for name, value := range t.headers {
+		if !knownHost && http.CanonicalHeaderKey(name) != "User-Agent" {
+			continue
+		}
+		if len(req.Header.Values(name)) == 0 {
+			req.Header.Set(name, value)
+		}
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// requestHeaders returns the headers of the [headers] section plus the User-Agent, unless the section sets it
+func requestHeaders(c *Configuration) map[string]string {
+	headers := map[string]string{"User-Agent": c.UserAgent}
+	for name, value := range c.Headers {
+		if http.CanonicalHeaderKey(name) == "User-Agent" {
+			delete(headers, "User-Agent")
+		}
+		headers[name] = value
+	}
+
+	return headers
+}
+
+// headerHosts returns the hosts (and ports) of the API URL and the file server URL, which often sit behind the
+// same authenticating proxy; URLs that can't be parsed are left out
+func headerHosts(c *Configuration) map[string]bool {
+	hosts := make(map[string]bool)
+	for _, rawURL := range []string{c.ApiUrl, c.FileServerURL} {
+		if u, err := url.Parse(rawURL); err == nil && len(u.Host) > 0 {
+			hosts[strings.ToLower(u.Host)] = true
+		}
+	}
+
+	return hosts
+}
+
+// checkRedirect logs every redirect and makes sure the auth token is only sent to the host it was meant
+// for, e.g. when downloads are redirected to a file server on another domain
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	previous := via[len(via)-1]
+	slog.Debug("Following redirect", "from", previous.URL.Redacted(), "to", req.URL.Redacted(),
+		"status", req.Response.StatusCode)
+
+	if req.URL.Host != via[0].URL.Host && len(req.Header.Get("Authorization")) > 0 {
+		slog.Debug("Not sending the auth token to another host", "host", req.URL.Host)
+		req.Header.Del("Authorization")
+	}
+
+	return nil
+}
+
+// configureProxy makes the transport use the configured proxy; without one, DefaultTransport already
+// honors the proxy environment variables
+func configureProxy(transport *http.Transport, c *Configuration) error {
+	if len(c.Proxy) > 0 {
+		proxyURL, err := url.Parse(c.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %q: %v", c.Proxy, err)
+		}
+
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", proxyURL.Scheme)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	apiURL, err := url.Parse(c.ApiUrl)
+	if err != nil {
+		return nil
+	}
+
+	proxyURL, err := transport.Proxy(&http.Request{URL: apiURL})
+	if err == nil && proxyURL != nil {
+		slog.Debug("Using proxy", "proxy", proxyURL.Redacted())
+	} else {
+		slog.Debug("Not using a proxy")
+	}
+
+	return nil
+}
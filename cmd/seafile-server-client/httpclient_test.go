@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientTimeouts(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		configure   func(c *Configuration)
+		transfer    bool
+		wantTimeout bool
+	}{
+		{"overall timeout", func(c *Configuration) { c.Timeout = 50 * time.Millisecond }, false, true},
+		{"response header timeout", func(c *Configuration) { c.ResponseHeaderTimeout = 50 * time.Millisecond }, false, true},
+		{"fast enough", func(c *Configuration) { c.Timeout = 5 * time.Second }, false, false},
+		{"transfers use their own timeout", func(c *Configuration) {
+			c.Timeout = 50 * time.Millisecond
+			c.DownloadTimeout = 0
+		}, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := defaultConfiguration()
+			c.ApiUrl = server.URL + "/api2"
+			c.Timeout, c.ResponseHeaderTimeout, c.DownloadTimeout = 0, 0, 0
+			test.configure(c)
+
+			api, transfer, err := newHTTPClients(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := api
+			if test.transfer {
+				client = transfer
+			}
+
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			var netErr net.Error
+			timedOut := errors.As(err, &netErr) && netErr.Timeout()
+			if timedOut != test.wantTimeout {
+				t.Errorf("got error %v, want a timeout: %v", err, test.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestCustomHeadersStayOnTheConfiguredHosts(t *testing.T) {
+	received := make(map[string]http.Header)
+	var mu sync.Mutex
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[name] = r.Header.Clone()
+			mu.Unlock()
+		}
+	}
+
+	// Another port is another host, as far as sending secrets along is concerned
+	other := httptest.NewServer(record("other"))
+	defer other.Close()
+	fileServer := httptest.NewServer(record("file server"))
+	defer fileServer.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record("api")(w, r)
+		http.Redirect(w, r, other.URL+"/seafhttp/files/1", http.StatusFound)
+	}))
+	defer api.Close()
+
+	c := defaultConfiguration()
+	c.ApiUrl = api.URL + "/api2"
+	c.FileServerURL = fileServer.URL + "/seafhttp"
+	c.Headers = map[string]string{"CF-Access-Client-Secret": "secret"}
+
+	apiClient, transferClient, err := newHTTPClients(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := apiClient.Get(api.URL + "/api2/repos/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = transferClient.Get(fileServer.URL + "/seafhttp/files/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		host       string
+		wantSecret string
+	}{
+		{"api", "secret"},
+		{"file server", "secret"},
+		{"other", ""},
+	}
+	for _, test := range tests {
+		header, ok := received[test.host]
+		if !ok {
+			t.Fatalf("no request reached the %s host", test.host)
+		}
+		if got := header.Get("CF-Access-Client-Secret"); got != test.wantSecret {
+			t.Errorf("%s host got the secret header %q, want %q", test.host, got, test.wantSecret)
+		}
+		if got := header.Get("User-Agent"); got != c.UserAgent {
+			t.Errorf("%s host got User-Agent %q, want %q", test.host, got, c.UserAgent)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+const listingTimeFormat = "2006-01-02 15:04"
+
+// Output formats of -list-libraries
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// printListing writes the entries in the style of ls -l: type, size, modification time and name
+func printListing(w io.Writer, entries []seafile.DirEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	for _, entry := range entries {
+		kind, name := "-", entry.Name
+		if entry.IsDir() {
+			kind, name = "d", entry.Name+"/"
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", kind, entry.Size, entry.ModTime().Format(listingTimeFormat), name)
+	}
+
+	return tw.Flush()
+}
+
+// printTree writes the tree below root in the style of tree(1), with the size of every entry, under title.
+// It walks the tree with a stack rather than recursively, as trees may be deep.
+func printTree(w io.Writer, title string, root *seafile.TreeNode) error {
+	type line struct {
+		node   *seafile.TreeNode
+		indent string
+		last   bool
+	}
+
+	push := func(stack []line, node *seafile.TreeNode, indent string) []line {
+		for i := len(node.Children) - 1; i >= 0; i-- {
+			stack = append(stack, line{node: node.Children[i], indent: indent, last: i == len(node.Children)-1})
+		}
+		return stack
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s (%s)\n", title, formatBytes(root.Size))
+
+	stack := push(nil, root, "")
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		branch, childIndent := "├── ", current.indent+"│   "
+		if current.last {
+			branch, childIndent = "└── ", current.indent+"    "
+		}
+
+		name, size := current.node.Name, formatBytes(current.node.Size)
+		if current.node.IsDir() {
+			name += "/"
+		}
+		if current.node.Truncated {
+			size = "..."
+		}
+
+		fmt.Fprintf(bw, "%s%s%s (%s)\n", current.indent, branch, name, size)
+		stack = push(stack, current.node, childIndent)
+	}
+
+	return bw.Flush()
+}
+
+// printLibraries writes one line per library: ID, size, whether it is encrypted, permission, owner and name
+func printLibraries(w io.Writer, libraries []seafile.Library) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, library := range libraries {
+		encrypted := "-"
+		if library.Encrypted {
+			encrypted = "encrypted"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", library.Id, formatBytes(library.Size), encrypted, library.Permission, library.Owner, library.Name)
+	}
+
+	return tw.Flush()
+}
+
+// printLibrariesJSON writes the libraries as an indented JSON array, with the field names the server uses
+func printLibrariesJSON(w io.Writer, libraries []seafile.Library) error {
+	if libraries == nil {
+		libraries = []seafile.Library{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(libraries)
+}
+
+// printAccountInfo writes the account and its space usage, one setting per line
+func printAccountInfo(w io.Writer, info seafile.AccountInfo) error {
+	total := "unlimited"
+	if !info.Unlimited() {
+		total = formatBytes(info.Total)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Email:\t%s\n", info.Email)
+	fmt.Fprintf(tw, "Name:\t%s\n", info.Name)
+	fmt.Fprintf(tw, "Usage:\t%s\n", formatBytes(info.Usage))
+	fmt.Fprintf(tw, "Total:\t%s\n", total)
+	return tw.Flush()
+}
+
+// printHistory writes one line per revision: commit ID, time, size, creator and description
+func printHistory(w io.Writer, commits []seafile.Commit) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, commit := range commits {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", commit.Id, commit.Time().Format(listingTimeFormat), commit.Size,
+			commit.Creator, commit.Description)
+	}
+
+	return tw.Flush()
+}
+
+// printTrash writes the deleted entries in the style of printListing, with the deletion time
+func printTrash(w io.Writer, entries []seafile.TrashEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	for _, entry := range entries {
+		kind, name := "-", entry.Path()
+		if entry.IsDir {
+			kind, name = "d", entry.Path()+"/"
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", kind, entry.Size, entry.Deleted.Format(listingTimeFormat), name)
+	}
+
+	return tw.Flush()
+}
+
+// printShareLinks writes one line per share link: token, expiry, views, library and path, and the URL
+func printShareLinks(w io.Writer, links []seafile.ShareLink) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, link := range links {
+		expires := "never"
+		if !link.Expires.IsZero() {
+			expires = link.Expires.Local().Format(listingTimeFormat)
+		}
+		if link.Expired() {
+			expires += " (expired)"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s:%s\t%s\n", link.Token, expires, link.ViewCount, link.LibraryName, link.Path, link.Link)
+	}
+
+	return tw.Flush()
+}
+
+// printSearchResults writes one line per result: library ID, path, size, modification time and the
+// matching snippet
+func printSearchResults(w io.Writer, results []seafile.SearchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, result := range results {
+		name := result.Path
+		if result.Dir {
+			name += "/"
+		}
+
+		snippet := strings.Join(strings.Fields(result.Snippet), " ")
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", result.LibraryId, name, result.Size,
+			result.ModTime().Format(listingTimeFormat), snippet)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lockFile is stored in the output directory, so runs into different output directories don't block each other
+const lockFile = ".seafile-client.lock"
+
+var errLocked = errors.New("another instance is using the output directory")
+
+// acquireLock locks the output directory against other instances until the returned function is called.
+// A process that crashes doesn't keep the lock.
+func acquireLock(outputDir string) (func(), error) {
+	if err := os.MkdirAll(outputDir, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(outputDir, lockFile)
+	release, err := tryLock(path)
+	if errors.Is(err, errLocked) {
+		if pid, ok := lockOwner(path); ok {
+			return nil, fmt.Errorf("%w (pid %d)", errLocked, pid)
+		}
+	}
+
+	return release, err
+}
+
+// outputDirectories returns the distinct directories the accounts download into: their output directories,
+// followed by those of the [library "name"] sections in order
+func outputDirectories(configs []*Configuration) []string {
+	var dirs, libraryDirs []string
+	seen := make(map[string]bool)
+	for _, c := range configs {
+		if dir := filepath.Clean(c.OutputDirectory); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+		for _, output := range c.LibraryOutputs {
+			if dir := filepath.Clean(output); !seen[dir] {
+				seen[dir] = true
+				libraryDirs = append(libraryDirs, dir)
+			}
+		}
+	}
+	sort.Strings(libraryDirs)
+
+	return append(dirs, libraryDirs...)
+}
+
+// lockOwner returns the process ID stored in the lock file
+func lockOwner(path string) (int, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid, err == nil && pid > 0
+}
+
+// writePid stores the ID of this process in the lock file, so a blocked instance can tell who holds it
+func writePid(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
@@ -0,0 +1,52 @@
+//go:build !unix
+
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// tryLock creates the file at path as a pid file, which is removed again on release. A lock file left
+// behind by a process that isn't running anymore is removed first.
+func tryLock(path string) (func(), error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, os.FileMode(0644))
+		if err == nil {
+			err = writePid(file)
+			file.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if pid, ok := lockOwner(path); ok && processRunning(pid) {
+			return nil, errLocked
+		}
+
+		slog.Warn("Removing stale lock file", "path", path)
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, errLocked
+}
+
+// processRunning reports whether a process with the ID exists. On Windows, FindProcess fails for processes
+// that don't; elsewhere it always succeeds, so locks are only considered stale when unreadable.
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	process.Release()
+	return true
+}
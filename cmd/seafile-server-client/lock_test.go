@@ -0,0 +1,21 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOutputDirectories(t *testing.T) {
+	configs := []*Configuration{
+		{OutputDirectory: "data", LibraryOutputs: map[string]string{"Photos": "/mnt/photos", "Music": "/mnt/music/"}},
+		{OutputDirectory: "data/", LibraryOutputs: map[string]string{"lib-1": "/mnt/photos"}},
+		{OutputDirectory: filepath.Join("data", "work")},
+	}
+
+	got := outputDirectories(configs)
+	want := []string{"data", filepath.Join("data", "work"), filepath.Clean("/mnt/music"), filepath.Clean("/mnt/photos")}
+	if !slices.Equal(got, want) {
+		t.Errorf("outputDirectories() = %q, want %q", got, want)
+	}
+}
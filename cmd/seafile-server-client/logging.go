@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// traceLogger logs what -trace asked for. It ignores the log level, so -quiet doesn't hide it.
+var traceLogger = slog.Default()
+
+// setupLogging replaces the default logger by one writing to w at the given level, as text or JSON, and
+// points traceLogger at w as well
+func setupLogging(w io.Writer, level string, jsonLogs bool) {
+	slog.SetDefault(newLogger(w, logLevels[strings.ToLower(level)], jsonLogs))
+	traceLogger = newLogger(w, slog.LevelDebug, jsonLogs)
+}
+
+func newLogger(w io.Writer, level slog.Level, jsonLogs bool) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	if jsonLogs {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// Exit codes of the process, as documented in the README
+const (
+	// exitFailure means something failed, e.g. a library couldn't be downloaded
+	exitFailure = 1
+	// exitAuthFailed means the server didn't accept the credentials of an account
+	exitAuthFailed = 2
+	// exitUsage means the flags were invalid or there was no configuration, like EX_USAGE of sysexits.h
+	exitUsage = 64
+)
+
+// fatal logs at error level and exits with exitFailure; only meant for problems that make it impossible
+// to continue
+func fatal(msg string, args ...any) {
+	fatalWithCode(exitFailure, msg, args...)
+}
+
+func fatalWithCode(code int, msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(code)
+}
+
+// exitCodeFor returns the exit code for err: exitAuthFailed when it is about the credentials, exitFailure
+// otherwise
+func exitCodeFor(err error) int {
+	if errors.Is(err, seafile.ErrAuthenticationFailed) || errors.Is(err, seafile.ErrUnauthorized) || errors.Is(err, seafile.ErrOTPRequired) {
+		return exitAuthFailed
+	}
+
+	return exitFailure
+}
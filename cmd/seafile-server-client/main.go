@@ -0,0 +1,1228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// version is set at build time using -ldflags "-X main.version=..."
+	version = "dev"
+)
+
+func main() {
+	// Set rather than calling os.Exit right away, so the deferred cleanup (e.g. releasing the locks) still runs
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	configPath := flag.String("config", configurationFile, "path to the configuration file")
+	outputDir := flag.String("output", "", "output directory, overrides the configuration file")
+	serverURL := flag.String("url", "", "server URL; when given, the configuration file is not read")
+	username := flag.String("username", "", "username, overrides the configuration file")
+	password := flag.String("password", "", "password, overrides the configuration file and the environment")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	otp := flag.String("otp", "", "two-factor authentication code, overrides the configuration file")
+	libraryFilter := flag.String("libraries", "", "comma-separated library names or IDs to download, overrides include")
+	uploadLink := flag.String("upload-link", "", "upload the file given by -upload through this public upload link, without logging in")
+	upload := flag.String("upload", "", "upload this local file instead of downloading libraries (requires -to)")
+	listPath := flag.String("ls", "", "list a directory, given as libraryID:/path, instead of downloading")
+	tree := flag.String("tree", "", "print the tree below libraryID:/path, or of all libraries with \"all\", instead of downloading")
+	treeDepth := flag.Int("depth", 0, "only descend this many levels with -tree (0 means no limit)")
+	share := flag.String("share", "", "create a share link for a file or directory, given as libraryID:/path")
+	sharePassword := flag.String("share-password", "", "protect the share link created with -share with this password")
+	shareExpireDays := flag.Int("share-expire-days", 0, "let the share link created with -share expire after this many days")
+	sharePermission := flag.String("share-permission", string(seafile.ShareDownload), "view or download")
+	search := flag.String("search", "", "search all libraries for files matching the term (needs a search index on the server)")
+	listLibraries := flag.Bool("list-libraries", false, "list the libraries of the account with their size")
+	listFormat := flag.String("format", formatTable, "output format of -list-libraries: table or json")
+	listShares := flag.Bool("list-shares", false, "list the share links of the account")
+	revokeShare := flag.String("revoke-share", "", "revoke the share link with this token")
+	trash := flag.String("trash", "", "list the trash of the library with this ID")
+	restoreDeleted := flag.String("restore-deleted", "", "restore this path from the trash given by -trash")
+	deletedAfter := flag.String("deleted-after", "", "only consider trash entries deleted on or after this date (YYYY-MM-DD)")
+	deletedBefore := flag.String("deleted-before", "", "only consider trash entries deleted before this date (YYYY-MM-DD)")
+	history := flag.String("history", "", "list the revisions of a file, given as libraryID:/path/to/file")
+	fileVersion := flag.String("download-version", "", "download the revision with this commit ID with -file")
+	remoteDir := flag.String("path", "", "download a directory, given as libraryID:/sub/dir, into the output directory")
+	thumbnail := flag.String("thumbnail", "", "download a thumbnail of the image libraryID:/path into the output directory")
+	thumbnailSize := flag.Int("size", 256, "size in pixels of the thumbnail given by -thumbnail")
+	remoteFile := flag.String("file", "", "download a single file, given as libraryID:/path/to/file, into the output directory")
+	mkdir := flag.String("mkdir", "", "create this libraryID:/path directory (succeeds if it already exists)")
+	move := flag.String("mv", "", "move this libraryID:/path into the directory given by -to")
+	rename := flag.String("rename", "", "rename this libraryID:/path to the name given by -new-name")
+	newName := flag.String("new-name", "", "new name for -rename")
+	restore := flag.String("restore", "", "upload this local directory tree into the library given by -to")
+	overwrite := flag.Bool("overwrite", false, "overwrite existing remote files when uploading or restoring, like -upload-policy overwrite")
+	uploadPolicy := flag.String("upload-policy", "", "what to do with existing remote files when uploading or restoring: skip, overwrite or rename (overrides upload_policy)")
+	uploadTarget := flag.String("to", "", "upload target as libraryID:/remote/dir")
+	createName := flag.String("create-library", "", "create a library with this name instead of downloading")
+	renameLibrary := flag.String("rename-library", "", "rename a library, given as libraryID:NewName")
+	deleteID := flag.String("delete-library", "", "delete the library with this ID instead of downloading")
+	confirmed := flag.Bool("confirm", false, "don't ask for confirmation before destructive operations")
+	dryRun := flag.Bool("dry-run", false, "only show what destructive operations would do")
+	libraryPassword := flag.String("library-password", "", "encrypt the library created with -create-library using this password")
+	refreshToken := flag.Bool("refresh-token", false, "ignore the cached auth token and authenticate again")
+	jsonLogs := flag.Bool("json-logs", false, "write logs as JSON")
+	allowInsecureHTTP := flag.Bool("allow-insecure-http", false, "allow a url with plain http, which sends the password unencrypted")
+	trace := flag.Bool("trace", false, "log every HTTP request and response, with the names of their headers")
+	traceBodies := flag.Bool("trace-bodies", false, "with -trace, also log the beginning of textual request and response bodies")
+	quiet := flag.Bool("quiet", false, "only report errors")
+	reportPath := flag.String("report", "", "write a JSON report of the run to this file")
+	showInfo := flag.Bool("info", false, "print the account info and exit")
+	starred := flag.Bool("starred", false, "only download the starred files, into <output>/starred")
+	mirror := flag.Bool("delete", false, "in incremental mode, delete local files that don't exist in the library anymore")
+	maxDelete := flag.Int("max-delete", 0, "with -delete, delete nothing if more than this many local entries would be deleted (0 means no limit)")
+	noLock := flag.Bool("no-lock", false, "don't lock the output directory against other instances")
+	configCheck := flag.Bool("check", false, "check the configuration, the connection and the login step by step with hints on failures, then exit")
+	healthCheck := flag.Bool("healthcheck", false, "check that the server is reachable and the login works, then exit (non-zero on failure)")
+	verify := flag.Bool("verify", false, "check the downloaded files against their "+seafile.ManifestName+" manifests instead of downloading")
+	keepZip := flag.Bool("keep-zip", false, "also save the zip of every library as <output>/<library>.zip")
+	extractNested := flag.Bool("extract-nested", false, "also extract the zip files found in libraries, into a directory next to each")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics on this address (e.g. :9100) while downloading")
+	since := flag.String("since", "", "only download libraries modified within this window, e.g. 7d or 12h")
+	countFiles := flag.Bool("count-files", false, "in incremental mode, count the files of libraries upfront for the progress if the server doesn't report it")
+	force := flag.Bool("force", false, "download libraries even if they didn't change since the last run")
+	account := flag.String("account", "", "only use the [account \"name\"] section with this name")
+	// Invalid flags exit with exitUsage rather than the 2 of flag.ExitOnError, which means the login failed
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		// The flag package already printed the error and the usage
+		if !errors.Is(err, flag.ErrHelp) {
+			exitCode = exitUsage
+		}
+		return
+	}
+
+	setupLogging(os.Stderr, "info", *jsonLogs)
+
+	if *showVersion {
+		fmt.Println("seafile-server-client", version)
+		return
+	}
+
+	var configs []*Configuration
+	var err error
+	noConfigFile := false
+	if len(*serverURL) > 0 {
+		// A one-off run configured by flags alone
+		configs = []*Configuration{defaultConfiguration()}
+	} else if configs, err = loadConfigs(*configPath); errors.Is(err, os.ErrNotExist) {
+		// Everything may still be provided through the environment
+		configs = []*Configuration{defaultConfiguration()}
+		noConfigFile = true
+	} else if err != nil {
+		fatal("Unable to parse configuration file", "error", err)
+	}
+
+	configs, err = selectAccount(configs, *account)
+	if err != nil {
+		fatal("Unknown account", "error", err)
+	}
+
+	for _, config := range configs {
+		applyEnvOverrides(config, len(configs) > 1)
+		if len(*serverURL) > 0 {
+			config.ApiUrl = *serverURL
+		}
+		if len(*username) > 0 {
+			config.Username = *username
+		}
+		if len(*password) > 0 {
+			config.Password = *password
+			config.PasswordFile = ""
+		}
+		if len(*outputDir) > 0 {
+			config.OutputDirectory = *outputDir
+			if len(configs) > 1 {
+				config.OutputDirectory = filepath.Join(*outputDir, config.Name)
+			}
+		}
+		if len(*libraryFilter) > 0 {
+			config.Include = splitList(*libraryFilter)
+		}
+
+		if *quiet {
+			config.LogLevel = "error"
+		}
+		if len(*uploadPolicy) > 0 {
+			config.UploadPolicy = *uploadPolicy
+		}
+		if *allowInsecureHTTP {
+			config.AllowInsecureHTTP = true
+		}
+		config.Trace = *trace || *traceBodies
+		config.TraceBodies = *traceBodies
+		if *overwrite {
+			config.UploadPolicy = string(seafile.UploadOverwrite)
+		}
+	}
+
+	// All accounts share the logger, so the level of the first one applies
+	setupLogging(os.Stderr, configs[0].LogLevel, *jsonLogs)
+
+	// Public upload links work without an account, so only the connection settings are used
+	if len(*uploadLink) > 0 {
+		if len(*upload) == 0 {
+			fatalWithCode(exitUsage, "-upload-link requires -upload")
+		}
+
+		dropDir := "/"
+		if len(*uploadTarget) > 0 {
+			dropDir = *uploadTarget
+		}
+
+		response, err := uploadViaLink(ctx, configs[0], *uploadLink, *upload, dropDir, *quiet)
+		if err != nil {
+			fatal("Unable to upload", "file", *upload, "error", err)
+		}
+
+		fmt.Println("Uploaded", *upload, "via", *uploadLink+":", response)
+		return
+	}
+
+	// Verifying only reads the output directories, so no account is needed either
+	if *verify {
+		failed := 0
+		for _, config := range configs {
+			checked, mismatches, err := verifyManifests(ctx, os.Stdout, config.OutputDirectory)
+			if err != nil {
+				fatal("Unable to verify", "output", config.OutputDirectory, "error", err)
+			}
+
+			slog.Info("Verified files", "output", config.OutputDirectory, "checked", checked, "failed", mismatches)
+			failed += mismatches
+		}
+
+		if failed > 0 {
+			fatal("Some files don't match their manifest", "failed", failed)
+		}
+		return
+	}
+
+	// Without a configuration file and the flags or environment to replace it, there is nothing to go on
+	if noConfigFile && len(configs[0].ApiUrl) == 0 {
+		fmt.Fprintf(flag.CommandLine.Output(), "No configuration file %s found; create one, or pass at least -url and -username.\n\n", *configPath)
+		flag.Usage()
+		exitCode = exitUsage
+		return
+	}
+
+	// With -check, invalid configurations are reported as its first step rather than ending the run
+	invalid := make(map[*Configuration]error)
+	for _, config := range configs {
+		// A password given on the command line beats the environment and the keyring
+		if len(*password) == 0 {
+			if err = resolvePassword(config); err != nil {
+				fatal("Unable to get password", "account", config.Name, "error", err)
+			}
+		}
+		if len(config.Password) == 0 && len(config.Username) > 0 {
+			if err = promptPassword(config); err != nil {
+				fatal("Unable to get password", "account", config.Name, "error", err)
+			}
+		}
+
+		if err = config.Validate(); err != nil {
+			if *configCheck {
+				invalid[config] = err
+				continue
+			}
+			fatal("Invalid configuration", "account", config.Name, "error", err)
+		}
+		if config.insecureHTTP() {
+			slog.Warn("INSECURE: the password and auth token are sent unencrypted over plain http, anyone on the network can read them",
+				"account", config.Name, "url", config.ApiUrl)
+		}
+
+		// The zip is stored next to the library's directory, which is the output directory itself
+		if *keepZip && config.Layout == layoutFlat && config.OutputFormat == string(seafile.FormatFiles) {
+			fatalWithCode(exitUsage, "-keep-zip can't be combined with layout = flat", "account", config.Name)
+		}
+
+		// Only a full download extracts an archive; incremental syncs would see the extracted files as extraneous
+		if *extractNested && (config.SyncMode != syncModeFull || config.OutputFormat != string(seafile.FormatFiles)) {
+			fatalWithCode(exitUsage, "-extract-nested needs sync_mode = full and output_format = files", "account", config.Name)
+		}
+
+		// With the flat layout, every library would delete the files of all others
+		if *mirror && (config.SyncMode != syncModeIncremental || config.Layout == layoutFlat) {
+			fatalWithCode(exitUsage, "-delete needs sync_mode = incremental and a layout other than flat", "account", config.Name)
+		}
+	}
+
+	opts := runOptions{RefreshToken: *refreshToken, OTP: *otp, Quiet: *quiet, Force: *force, KeepZip: *keepZip, ExtractNested: *extractNested,
+		CountFiles: *countFiles, Delete: *mirror, MaxDelete: *maxDelete, DryRun: *dryRun, Metrics: &transferMetrics{}}
+	if len(*since) > 0 {
+		window, err := parseSince(*since)
+		if err != nil {
+			fatalWithCode(exitUsage, "Invalid -since", "error", err)
+		}
+		opts.ModifiedSince = time.Now().Add(-window)
+	}
+
+	// Like -healthcheck, but for a person setting up the configuration
+	if *configCheck {
+		passed := true
+		for _, config := range configs {
+			passed = checkConfig(ctx, os.Stdout, config, invalid[config], opts, len(configs) > 1) && passed
+		}
+
+		if !passed {
+			exitCode = exitFailure
+		}
+		return
+	}
+
+	// Checks the connection without touching the output directory
+	if *healthCheck {
+		healthy := true
+		for _, config := range configs {
+			healthy = checkHealth(ctx, os.Stdout, config, opts, len(configs) > 1) && healthy
+		}
+
+		if !healthy {
+			exitCode = exitFailure
+		}
+		return
+	}
+
+	if len(*fileVersion) > 0 && len(*remoteFile) == 0 {
+		fatalWithCode(exitUsage, "-download-version requires -file")
+	}
+
+	if len(*move) > 0 && len(*uploadTarget) == 0 {
+		fatalWithCode(exitUsage, "-mv requires -to")
+	}
+
+	if len(*rename) > 0 && len(*newName) == 0 {
+		fatalWithCode(exitUsage, "-rename requires -new-name")
+	}
+
+	if len(*uploadPolicy) > 0 && !slices.Contains(uploadPolicies, *uploadPolicy) {
+		fatalWithCode(exitUsage, "Invalid -upload-policy, expected skip, overwrite or rename", "policy", *uploadPolicy)
+	}
+
+	if *listFormat != formatTable && *listFormat != formatJSON {
+		fatalWithCode(exitUsage, "Invalid -format, expected table or json", "format", *listFormat)
+	}
+
+	// Everything but downloading works on a single account
+	config := configs[0]
+	var client *seafile.Client
+	singleAccount := singleAccountRun(flag.CommandLine)
+
+	// Two runs writing into the same output directory would corrupt each other's files
+	writesOutput := !singleAccount || *starred || len(*remoteFile) > 0 || len(*thumbnail) > 0 || len(*remoteDir) > 0
+	// Once locked, failures end main through fail and return rather than fatal, as os.Exit would skip the
+	// deferred release of the locks
+	fail := func(code int, msg string, args ...any) {
+		slog.Error(msg, args...)
+		exitCode = code
+	}
+
+	if writesOutput && !*noLock {
+		for _, dir := range outputDirectories(configs) {
+			release, err := acquireLock(dir)
+			if err != nil {
+				fail(exitFailure, "Unable to lock the output directory, use -no-lock to run anyway", "output", dir, "error", err)
+				return
+			}
+			defer release()
+
+			// Only while locked, as the temporary files may belong to a running instance otherwise
+			if removed, err := seafile.RemoveTempFiles(dir); err != nil {
+				slog.Warn("Unable to remove temporary files of an earlier run", "output", dir, "error", err)
+			} else if removed > 0 {
+				slog.Info("Removed temporary files of an interrupted run", "output", dir, "files", removed)
+			}
+		}
+	}
+
+	if singleAccount {
+		if len(configs) > 1 {
+			fail(exitFailure, "This operation works on a single account, select one with -account")
+			return
+		}
+
+		client, err = connect(ctx, config, opts)
+		if err != nil {
+			fail(exitCodeFor(err), "Unable to connect", "url", config.ApiUrl, "error", err)
+			return
+		}
+	}
+
+	if *showInfo {
+		info, err := client.AccountInfo(ctx)
+		if err != nil {
+			fail(exitFailure, "Unable to get account info", "error", err)
+			return
+		}
+
+		printAccountInfo(os.Stdout, info)
+		return
+	}
+
+	if len(*search) > 0 {
+		results, err := client.SearchFiles(ctx, *search)
+		if errors.Is(err, seafile.ErrSearchUnavailable) {
+			fail(exitFailure, "This server does not support search, it needs Seafile Professional with the search index enabled", "error", err)
+			return
+		} else if err != nil {
+			fail(exitFailure, "Unable to search", "query", *search, "error", err)
+			return
+		}
+
+		printSearchResults(os.Stdout, results)
+		return
+	}
+
+	if *listLibraries {
+		libraries, err := client.ListLibraries(ctx, config.libraryTypes()...)
+		if err != nil {
+			fail(exitFailure, "Unable to list libraries", "error", err)
+			return
+		}
+
+		if *listFormat == formatJSON {
+			err = printLibrariesJSON(os.Stdout, libraries)
+		} else {
+			err = printLibraries(os.Stdout, libraries)
+		}
+		if err != nil {
+			fail(exitFailure, "Unable to print libraries", "error", err)
+			return
+		}
+		return
+	}
+
+	if *starred {
+		downloaded, err := downloadStarred(ctx, client, config)
+		if err != nil {
+			fail(exitFailure, "Unable to download starred files", "downloaded", downloaded, "error", err)
+			return
+		}
+
+		fmt.Println("Downloaded", downloaded, "starred files to", filepath.Join(config.OutputDirectory, starredDirectory))
+		return
+	}
+
+	if len(*upload) > 0 {
+		libraryID, remoteDir, err := parseRemotePath(*uploadTarget)
+		if err != nil {
+			fail(exitFailure, "Invalid upload target", "error", err)
+			return
+		}
+
+		response, err := client.UploadFile(ctx, libraryID, *upload, remoteDir, seafile.UploadPolicy(config.UploadPolicy))
+		if errors.Is(err, seafile.ErrFileExists) {
+			fmt.Println("Not uploaded", *upload+":", err.Error()+"; use -upload-policy overwrite or rename to upload anyway")
+			return
+		} else if err != nil {
+			fail(exitFailure, "Unable to upload", "file", *upload, "error", err)
+			return
+		}
+
+		fmt.Println("Uploaded", *upload, "to", *uploadTarget+":", response)
+		return
+	}
+
+	if len(*listPath) > 0 {
+		libraryID, dirPath, err := parseRemotePath(*listPath)
+		if err != nil {
+			fail(exitFailure, "Invalid directory", "error", err)
+			return
+		}
+
+		entries, err := client.ListDirectory(ctx, libraryID, dirPath)
+		if err != nil {
+			fail(exitFailure, "Unable to list directory", "path", *listPath, "error", err)
+			return
+		}
+
+		printListing(os.Stdout, entries)
+		return
+	}
+
+	if len(*tree) > 0 {
+		if err = printTrees(ctx, os.Stdout, client, config, *tree, *treeDepth); err != nil {
+			fail(exitFailure, "Unable to list the tree", "path", *tree, "error", err)
+			return
+		}
+		return
+	}
+
+	if len(*share) > 0 {
+		libraryID, sharePath, err := parseRemotePath(*share)
+		if err != nil {
+			fail(exitFailure, "Invalid share path", "error", err)
+			return
+		}
+
+		link, err := client.CreateShareLink(ctx, libraryID, sharePath, seafile.ShareOptions{
+			Password:   *sharePassword,
+			ExpireDays: *shareExpireDays,
+			Permission: seafile.SharePermission(*sharePermission),
+		})
+		if err != nil {
+			fail(exitFailure, "Unable to create share link", "path", *share, "error", err)
+			return
+		}
+
+		fmt.Println(link)
+		return
+	}
+
+	if *listShares {
+		links, err := client.ListShareLinks(ctx)
+		if err != nil {
+			fail(exitFailure, "Unable to list share links", "error", err)
+			return
+		}
+
+		printShareLinks(os.Stdout, links)
+		return
+	}
+
+	if len(*revokeShare) > 0 {
+		if err = client.DeleteShareLink(ctx, *revokeShare); err != nil {
+			fail(exitFailure, "Unable to revoke share link", "token", *revokeShare, "error", err)
+			return
+		}
+
+		fmt.Println("Revoked share link", *revokeShare)
+		return
+	}
+
+	if len(*trash) > 0 {
+		after, err := parseTrashDate(*deletedAfter)
+		if err != nil {
+			fail(exitFailure, "Invalid -deleted-after", "error", err)
+			return
+		}
+		before, err := parseTrashDate(*deletedBefore)
+		if err != nil {
+			fail(exitFailure, "Invalid -deleted-before", "error", err)
+			return
+		}
+
+		entries, err := client.ListTrash(ctx, *trash)
+		if err != nil {
+			fail(exitFailure, "Unable to list trash", "id", *trash, "error", err)
+			return
+		}
+		entries = filterTrash(entries, after, before)
+
+		if len(*restoreDeleted) == 0 {
+			printTrash(os.Stdout, entries)
+			return
+		}
+
+		entry, ok := latestDeletion(entries, path.Clean("/"+*restoreDeleted))
+		if !ok {
+			fail(exitFailure, "Not found in the trash", "path", *restoreDeleted)
+			return
+		}
+
+		if err = client.RestoreFromTrash(ctx, *trash, entry); err != nil {
+			fail(exitFailure, "Unable to restore from trash", "path", entry.Path(), "error", err)
+			return
+		}
+
+		fmt.Println("Restored", entry.Path(), "deleted at", entry.Deleted.Format(listingTimeFormat))
+		return
+	}
+
+	if len(*history) > 0 {
+		libraryID, filePath, err := parseRemotePath(*history)
+		if err != nil {
+			fail(exitFailure, "Invalid file", "error", err)
+			return
+		}
+
+		commits, err := client.ListFileHistory(ctx, libraryID, filePath)
+		if err != nil {
+			fail(exitFailure, "Unable to list file history", "path", *history, "error", err)
+			return
+		}
+
+		printHistory(os.Stdout, commits)
+		return
+	}
+
+	if len(*remoteFile) > 0 {
+		libraryID, remotePath, err := parseRemotePath(*remoteFile)
+		if err != nil {
+			fail(exitFailure, "Invalid file", "error", err)
+			return
+		}
+
+		localPath := filepath.Join(config.OutputDirectory, path.Base(remotePath))
+		if len(*fileVersion) > 0 {
+			err = client.DownloadFileVersion(ctx, libraryID, remotePath, *fileVersion, localPath)
+		} else {
+			err = client.DownloadFile(ctx, libraryID, remotePath, localPath)
+		}
+		if err != nil {
+			fail(exitFailure, "Unable to download file", "file", *remoteFile, "error", err)
+			return
+		}
+
+		fmt.Println("Downloaded", *remoteFile, "to", localPath)
+		return
+	}
+
+	if len(*thumbnail) > 0 {
+		libraryID, remotePath, err := parseRemotePath(*thumbnail)
+		if err != nil {
+			fail(exitFailure, "Invalid file", "error", err)
+			return
+		}
+
+		data, err := client.Thumbnail(ctx, libraryID, remotePath, *thumbnailSize)
+		if errors.Is(err, seafile.ErrNotAnImage) {
+			fail(exitFailure, "The server only creates thumbnails of images", "file", *thumbnail, "error", err)
+			return
+		} else if err != nil {
+			fail(exitFailure, "Unable to download thumbnail", "file", *thumbnail, "error", err)
+			return
+		}
+
+		localPath := filepath.Join(config.OutputDirectory, thumbnailName(remotePath, *thumbnailSize, data))
+		if err = ioutil.WriteFile(localPath, data, os.FileMode(0644)); err != nil {
+			fail(exitFailure, "Unable to save thumbnail", "path", localPath, "error", err)
+			return
+		}
+
+		fmt.Println("Downloaded thumbnail of", *thumbnail, "to", localPath)
+		return
+	}
+
+	if len(*remoteDir) > 0 {
+		libraryID, dirPath, err := parseRemotePath(*remoteDir)
+		if err != nil {
+			fail(exitFailure, "Invalid directory", "error", err)
+			return
+		}
+
+		stats, err := client.DownloadDirectory(ctx, libraryID, dirPath, config.OutputDirectory)
+		if err != nil {
+			fail(exitFailure, "Unable to download directory", "path", *remoteDir, "error", err)
+			return
+		}
+
+		fmt.Println("Downloaded", stats.Files, "files from", *remoteDir, "to", config.OutputDirectory)
+		return
+	}
+
+	if len(*createName) > 0 {
+		library, err := client.CreateLibrary(ctx, *createName, len(*libraryPassword) > 0, *libraryPassword)
+		if err != nil {
+			fail(exitFailure, "Unable to create library", "error", err)
+			return
+		}
+
+		fmt.Println("Created library", library.Name, "with ID", library.Id)
+		return
+	}
+
+	if len(*renameLibrary) > 0 {
+		libraryID, name, found := strings.Cut(*renameLibrary, ":")
+		if !found || len(libraryID) == 0 || len(strings.TrimSpace(name)) == 0 {
+			fail(exitFailure, "Invalid -rename-library, expected libraryID:NewName", "value", *renameLibrary)
+			return
+		}
+
+		err = client.RenameLibrary(ctx, libraryID, name)
+		if errors.Is(err, seafile.ErrLibraryNameRejected) {
+			fail(exitFailure, "The server refused the name, another library may already have it", "name", name, "error", err)
+			return
+		} else if err != nil {
+			fail(exitFailure, "Unable to rename library", "id", libraryID, "error", err)
+			return
+		}
+
+		fmt.Println("Renamed library", libraryID, "to", name)
+		return
+	}
+
+	if len(*deleteID) > 0 {
+		if *dryRun {
+			fmt.Println("Dry run: would delete library", *deleteID)
+			return
+		}
+
+		if !*confirmed && !confirm("Permanently delete library "+*deleteID+"?") {
+			fail(exitFailure, "Not deleting library: use -confirm or answer the prompt with y", "id", *deleteID)
+			return
+		}
+
+		err = client.DeleteLibrary(ctx, *deleteID)
+		if errors.Is(err, seafile.ErrLibraryNotFound) {
+			fmt.Println("Library", *deleteID, "does not exist (anymore)")
+			return
+		} else if err != nil {
+			fail(exitFailure, "Unable to delete library", "id", *deleteID, "error", err)
+			return
+		}
+
+		fmt.Println("Deleted library", *deleteID)
+		return
+	}
+
+	if len(*mkdir) > 0 {
+		libraryID, dirPath, err := parseRemotePath(*mkdir)
+		if err != nil {
+			fail(exitFailure, "Invalid directory", "error", err)
+			return
+		}
+
+		if err = client.MakeDir(ctx, libraryID, dirPath, true); err != nil {
+			fail(exitFailure, "Unable to create directory", "path", *mkdir, "error", err)
+			return
+		}
+
+		fmt.Println("Created", *mkdir)
+		return
+	}
+
+	if len(*move) > 0 {
+		libraryID, srcPath, err := parseRemotePath(*move)
+		if err != nil {
+			fail(exitFailure, "Invalid path", "error", err)
+			return
+		}
+
+		dstDir := *uploadTarget
+		if !strings.HasPrefix(dstDir, "/") {
+			dstDir = "/" + dstDir
+		}
+
+		entry, err := remoteEntry(ctx, client, libraryID, srcPath)
+		if err != nil {
+			fail(exitFailure, "Unable to move", "path", *move, "error", err)
+			return
+		}
+
+		result, err := client.MoveEntry(ctx, libraryID, srcPath, dstDir, entry.IsDir())
+		if err != nil {
+			fail(exitFailure, "Unable to move", "path", *move, "to", dstDir, "error", err)
+			return
+		}
+
+		printMoveResult(srcPath, result)
+		return
+	}
+
+	if len(*rename) > 0 {
+		libraryID, srcPath, err := parseRemotePath(*rename)
+		if err != nil {
+			fail(exitFailure, "Invalid path", "error", err)
+			return
+		}
+
+		if strings.ContainsAny(*newName, "/\\") {
+			fail(exitFailure, "The new name must not contain a slash", "name", *newName)
+			return
+		}
+
+		entry, err := remoteEntry(ctx, client, libraryID, srcPath)
+		if err != nil {
+			fail(exitFailure, "Unable to rename", "path", *rename, "error", err)
+			return
+		}
+
+		result, err := client.RenameEntry(ctx, libraryID, srcPath, *newName, entry.IsDir())
+		if err != nil {
+			fail(exitFailure, "Unable to rename", "path", *rename, "name", *newName, "error", err)
+			return
+		}
+
+		printMoveResult(srcPath, result)
+		return
+	}
+
+	if len(*restore) > 0 {
+		libraryID, _, err := parseRemotePath(*uploadTarget)
+		if err != nil {
+			fail(exitFailure, "Invalid restore target", "error", err)
+			return
+		}
+
+		err = client.RestoreLibrary(ctx, libraryID, *restore, seafile.UploadPolicy(config.UploadPolicy))
+		if err != nil {
+			fail(exitFailure, "Unable to restore", "path", *restore, "error", err)
+			return
+		}
+		return
+	}
+
+	stopMetrics := func() {}
+	if len(*metricsAddr) > 0 {
+		stopMetrics, err = serveMetrics(*metricsAddr, opts.Metrics)
+		if err != nil {
+			fail(exitFailure, "Unable to serve metrics", "addr", *metricsAddr, "error", err)
+			return
+		}
+	}
+
+	start := time.Now()
+	opts.Started = start
+	var results []libraryResult
+	for _, config := range configs {
+		accountResults, err := syncAccount(ctx, config, opts)
+		if err != nil && len(configs) == 1 {
+			fail(exitCodeFor(err), "Unable to synchronize", "url", config.ApiUrl, "error", err)
+			return
+		} else if err != nil {
+			slog.Error("Unable to synchronize account", "account", config.Name, "error", err)
+			exitCode = max(exitCode, exitCodeFor(err))
+		}
+		results = append(results, accountResults...)
+	}
+	stopMetrics()
+
+	var total seafile.Stats
+	failed, skipped, empty := 0, 0, 0
+	for _, result := range results {
+		total.Files += result.Stats.Files
+		total.Bytes += result.Stats.Bytes
+		if result.Err != nil {
+			slog.Error("Library failed", "account", result.Account, "library", result.Library.Name, "id", result.Library.Id, "error", result.Err)
+			failed++
+		} else if result.Skipped {
+			skipped++
+		} else if result.Empty {
+			empty++
+		}
+	}
+	downloaded := len(results) - failed - skipped - empty
+
+	if !*quiet {
+		elapsed := time.Since(start)
+		summary := fmt.Sprintf("downloaded %d libraries, %d files, %s in %s (%s)", downloaded, total.Files,
+			formatBytes(total.Bytes), elapsed.Round(time.Second), formatRate(total.Bytes, elapsed))
+		if skipped > 0 {
+			summary += fmt.Sprintf(", %d unchanged", skipped)
+		}
+		if empty > 0 {
+			summary += fmt.Sprintf(", %d empty", empty)
+		}
+		fmt.Println(summary)
+	}
+	if len(*reportPath) > 0 {
+		if err := writeReport(*reportPath, newRunReport(start, results)); err != nil {
+			slog.Error("Unable to write report", "path", *reportPath, "error", err)
+		}
+	}
+	if failed > 0 {
+		slog.Error("Some libraries failed to download", "failed", failed, "total", len(results))
+		exitCode = max(exitCode, exitFailure)
+	}
+
+	// Also after failures, e.g. to send a notification; the environment tells how the run went
+	if hook := configs[0].PostRunHook; len(hook) > 0 {
+		env := runHookEnv(downloaded, failed, skipped, empty, *reportPath)
+		if err := runHook(ctx, "post_run_hook", hook, env); err != nil {
+			slog.Error("Post-run hook failed", "error", err)
+			exitCode = max(exitCode, exitFailure)
+		}
+	}
+}
+
+// singleAccountFlags select the operations that work on a single account, rather than downloading the
+// libraries of all of them
+var singleAccountFlags = []string{
+	"info", "list-libraries", "list-shares", "starred", "search", "ls", "tree", "history", "share", "revoke-share",
+	"trash", "file", "thumbnail", "path", "upload", "restore", "mkdir", "mv", "rename", "create-library",
+	"rename-library", "delete-library",
+}
+
+// singleAccountRun reports whether the run is a single-account operation: one of singleAccountFlags is set to
+// something other than false or an empty string. Any other run downloads the libraries of all accounts.
+func singleAccountRun(flags *flag.FlagSet) bool {
+	for _, name := range singleAccountFlags {
+		if value := flags.Lookup(name).Value.String(); len(value) > 0 && value != "false" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runOptions are the flags that apply to every account
+type runOptions struct {
+	RefreshToken bool
+	OTP          string
+	Quiet        bool
+	Force        bool
+	KeepZip      bool
+	// ExtractNested extracts zip files within libraries, see seafile.Client.ExtractNested
+	ExtractNested bool
+	// CountFiles counts the files of libraries upfront, see seafile.Client.CountFiles
+	CountFiles bool
+	// Delete removes local files that don't exist in their library anymore, see seafile.Client.DeleteExtraneous
+	Delete    bool
+	MaxDelete int
+	DryRun    bool
+	// Metrics collects the transfers of all accounts
+	Metrics *transferMetrics
+	// ModifiedSince skips libraries that weren't modified since; the zero time selects all libraries
+	ModifiedSince time.Time
+	// Started is when the run started, which names its snapshot directories
+	Started time.Time
+}
+
+// newClient creates an unauthenticated client for the account of c
+func newClient(c *Configuration, opts runOptions) (*seafile.Client, error) {
+	apiClient, transferClient, err := newHTTPClients(c)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up HTTP client: %w", err)
+	}
+
+	client := seafile.NewClient(c.ApiUrl)
+	client.HTTPClient = apiClient
+	client.TransferClient = transferClient
+	client.MaxRetries = c.MaxRetries
+	client.RetryDelay = c.RetryDelay
+	if c.RateLimit > 0 {
+		client.RateLimiter = rate.NewLimiter(rate.Limit(c.RateLimit), int(math.Max(1, c.RateLimit)))
+	}
+	client.Connections = make(chan struct{}, c.MaxConnections)
+	if bandwidth, _ := parseBandwidth(c.BandwidthLimit); bandwidth > 0 {
+		// Bursts of up to a second; small limits still need room for a reasonable read
+		client.BandwidthLimiter = rate.NewLimiter(rate.Limit(bandwidth), int(math.Max(bandwidth, 4096)))
+	}
+	client.FileServerURL = c.FileServerURL
+	client.MaxFileSize, _ = c.maxFileSize()
+	client.FileInclude = c.FileInclude
+	client.FileExclude = c.FileExclude
+	client.TempDir = c.TempDirectory
+	client.OutputFormat = seafile.OutputFormat(c.OutputFormat)
+	client.CompressionLevel = c.CompressionLevel
+	client.ExtractWorkers = c.ExtractWorkers
+	client.KeepZip = opts.KeepZip
+	client.ExtractNested = opts.ExtractNested
+	client.CountFiles = opts.CountFiles
+	client.Manifest = c.Manifest
+	client.DeleteExtraneous = opts.Delete
+	client.MaxDelete = opts.MaxDelete
+	client.DryRun = opts.DryRun
+	if !opts.Quiet {
+		client.Progress = newProgressReporter()
+	}
+
+	return client, nil
+}
+
+// connect creates a client for the account of c and authenticates it. A cached token is reused as
+// long as the server accepts it.
+func connect(ctx context.Context, c *Configuration, opts runOptions) (*seafile.Client, error) {
+	client, err := newClient(c, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(c.OutputDirectory, os.FileMode(0755))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create output directory %s: %w", c.OutputDirectory, err)
+	}
+
+	err = client.Ping(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to ping: %w", err)
+	}
+
+	// Only used to adapt to the server, so a server that doesn't tell is assumed to support everything
+	if server, err := client.GetServerInfo(ctx); err != nil {
+		slog.Warn("Unable to detect the server version", "account", c.Name, "error", err)
+	} else {
+		slog.Info("Connected to Seafile", "account", c.Name, "version", server.Version, "pro", server.HasFeature(seafile.FeaturePro))
+	}
+
+	if !opts.RefreshToken {
+		client.Token, err = readCachedToken(c)
+		if err != nil {
+			slog.Warn("Unable to read cached auth token", "account", c.Name, "error", err)
+		}
+	}
+
+	if len(client.Token) > 0 {
+		err = client.AuthPing(ctx)
+		if errors.Is(err, seafile.ErrUnauthorized) {
+			client.Token = ""
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to auth ping: %w", err)
+		}
+	}
+
+	if len(client.Token) == 0 {
+		err = authenticate(ctx, client, c, opts.OTP)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get auth token: %w", err)
+		}
+
+		err = client.AuthPing(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to auth ping: %w", err)
+		}
+
+		err = writeCachedToken(c, client.Token)
+		if err != nil {
+			slog.Warn("Unable to cache auth token", "account", c.Name, "error", err)
+		}
+	}
+
+	// A token that expires during a long run is renewed. That only works without two-factor authentication:
+	// there's nobody to ask for a code by then, and a configured code is used up once it got the first token.
+	client.Reauthenticate = func(ctx context.Context) (string, error) {
+		if len(c.OTP) > 0 || len(opts.OTP) > 0 {
+			return "", fmt.Errorf("%w: the auth token expired and the two-factor code was already used, "+
+				"run again with a new code", seafile.ErrOTPRequired)
+		}
+
+		token, err := client.GetToken(ctx, c.Username, c.Password, "")
+		if err != nil {
+			return "", err
+		}
+
+		if err = writeCachedToken(c, token); err != nil {
+			slog.Warn("Unable to cache auth token", "account", c.Name, "error", err)
+		}
+		return token, nil
+	}
+
+	return client, nil
+}
+
+// sortLibraries orders the libraries to download according to order; the server order is kept for
+// orderServer and for libraries of equal size
+func sortLibraries(libraries []seafile.Library, order string) {
+	switch order {
+	case orderLargest:
+		sort.SliceStable(libraries, func(i, j int) bool { return libraries[i].Size > libraries[j].Size })
+	case orderSmallest:
+		sort.SliceStable(libraries, func(i, j int) bool { return libraries[i].Size < libraries[j].Size })
+	}
+}
+
+// uploadViaLink uploads localPath through a public upload link, using the connection settings of c
+func uploadViaLink(ctx context.Context, c *Configuration, link, localPath, parentDir string, quiet bool) (string, error) {
+	apiClient, transferClient, err := newHTTPClients(c)
+	if err != nil {
+		return "", fmt.Errorf("unable to set up HTTP client: %w", err)
+	}
+
+	client := seafile.NewClient("")
+	client.HTTPClient = apiClient
+	client.TransferClient = transferClient
+	client.MaxRetries = c.MaxRetries
+	client.RetryDelay = c.RetryDelay
+	client.FileServerURL = c.FileServerURL
+	if !quiet {
+		client.Progress = newProgressReporter()
+	}
+
+	return client.UploadViaLink(ctx, link, localPath, parentDir)
+}
+
+// syncAccount downloads the selected libraries of the account of c. Libraries that didn't change since
+// their last successful download are skipped, unless opts.Force is set. In snapshot mode, everything is
+// downloaded into a new snapshot directory instead.
+func syncAccount(ctx context.Context, c *Configuration, opts runOptions) ([]libraryResult, error) {
+	if c.Snapshot {
+		snapshot := *c
+		snapshot.OutputDirectory = snapshotDir(c.OutputDirectory, opts.Started)
+		c = &snapshot
+	}
+
+	client, err := connect(ctx, c, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	libraries, err := client.ListLibraries(ctx, c.libraryTypes()...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list libraries: %w", err)
+	}
+
+	// Names are assigned before filtering, so a library keeps its directory whichever libraries are selected
+	dirNames := seafile.LibraryDirNames(libraries)
+	libraries = filterLibraries(libraries, c.Include, c.Exclude)
+	if !opts.ModifiedSince.IsZero() {
+		libraries = filterModifiedSince(libraries, opts.ModifiedSince)
+	}
+
+	state, err := loadState(c.OutputDirectory)
+	if err != nil {
+		slog.Warn("Unable to read the state of the previous run, downloading all libraries", "error", err)
+	}
+
+	var (
+		results []libraryResult
+		changed []seafile.Library
+	)
+	for _, library := range libraries {
+		if !opts.Force && state.unchanged(library.Id, library.HeadCommitId) {
+			slog.Info("Library unchanged since the last run, skipping", "library", library.Name)
+			results = append(results, libraryResult{Library: library, Skipped: true})
+			continue
+		}
+		changed = append(changed, library)
+	}
+
+	sortLibraries(changed, c.DownloadOrder)
+
+	var needed int64
+	for _, library := range changed {
+		needed += library.Size
+	}
+	if len(changed) > 0 {
+		slog.Info("Downloading libraries", "libraries", len(changed), "size", formatBytes(needed))
+	}
+
+	if c.SyncMode == syncModeFull && c.CheckDiskSpace && len(changed) > 0 {
+		checkDiskSpace(c.OutputDirectory, needed)
+	}
+
+	results = append(results, downloadLibraries(ctx, client, c, changed, dirNames, opts.Metrics)...)
+	for i, result := range results {
+		results[i].Account = c.Name
+		if result.Err == nil && !result.Skipped {
+			state.Commits[result.Library.Id] = result.Library.HeadCommitId
+		}
+	}
+
+	if err = state.save(c.OutputDirectory); err != nil {
+		slog.Warn("Unable to save the state of this run", "error", err)
+	}
+
+	if c.Snapshot && !slices.ContainsFunc(results, func(result libraryResult) bool { return result.Err != nil }) {
+		if err = finishSnapshot(c, c.OutputDirectory); err != nil {
+			slog.Warn("Unable to finish the snapshot", "account", c.Name, "snapshot", c.OutputDirectory, "error", err)
+		}
+	}
+
+	return results, nil
+}
+
+// thumbnailName names the thumbnail of remotePath after the image, its size and the format the server sent
+func thumbnailName(remotePath string, size int, data []byte) string {
+	ext := ".jpg"
+	if http.DetectContentType(data) == "image/png" {
+		ext = ".png"
+	}
+
+	name := strings.TrimSuffix(path.Base(remotePath), path.Ext(remotePath))
+	return fmt.Sprintf("%s-%d%s", name, size, ext)
+}
+
+// remoteEntry looks up the entry at entryPath in the listing of its parent directory
+func remoteEntry(ctx context.Context, client *seafile.Client, libraryID, entryPath string) (seafile.DirEntry, error) {
+	entryPath = path.Clean(entryPath)
+	if entryPath == "/" {
+		return seafile.DirEntry{}, errors.New("the root directory can't be moved or renamed")
+	}
+
+	entries, err := client.ListDirectory(ctx, libraryID, path.Dir(entryPath))
+	if err != nil {
+		return seafile.DirEntry{}, err
+	}
+
+	for _, entry := range entries {
+		if entry.Name == path.Base(entryPath) {
+			return entry, nil
+		}
+	}
+
+	return seafile.DirEntry{}, fmt.Errorf("%s does not exist", entryPath)
+}
+
+// printMoveResult prints where a moved or renamed entry ended up, pointing out when the server renamed it
+func printMoveResult(srcPath string, result seafile.MoveResult) {
+	if result.Renamed {
+		fmt.Println("Moved", srcPath, "to", result.Path, "(the destination already existed, so the server renamed it)")
+		return
+	}
+
+	fmt.Println("Moved", srcPath, "to", result.Path)
+}
+
+// printTrees prints the tree below target, a libraryID:/path or "all" for all libraries of the account that
+// are included
+func printTrees(ctx context.Context, w io.Writer, client *seafile.Client, c *Configuration, target string, depth int) error {
+	if target != "all" {
+		libraryID, dirPath, err := parseRemotePath(target)
+		if err != nil {
+			return err
+		}
+
+		root, err := client.WalkTree(ctx, libraryID, dirPath, depth, c.Concurrency)
+		if err != nil {
+			return err
+		}
+		return printTree(w, target, root)
+	}
+
+	libraries, err := client.ListLibraries(ctx, c.libraryTypes()...)
+	if err != nil {
+		return fmt.Errorf("unable to list libraries: %w", err)
+	}
+
+	for _, library := range filterLibraries(libraries, c.Include, c.Exclude) {
+		root, err := client.WalkTree(ctx, library.Id, "/", depth, c.Concurrency)
+		if err != nil {
+			// E.g. an encrypted library that is locked; the others can still be shown
+			slog.Warn("Unable to list library", "library", library.Name, "error", err)
+			continue
+		}
+		if err = printTree(w, library.Name+":/", root); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseRemotePath splits a "libraryID:/path" argument; the path defaults to the library root
+func parseRemotePath(value string) (libraryID, remotePath string, err error) {
+	libraryID, remotePath = value, "/"
+	if i := strings.Index(value, ":"); i >= 0 {
+		libraryID, remotePath = value[:i], value[i+1:]
+	}
+
+	if len(libraryID) == 0 {
+		return "", "", fmt.Errorf("missing library ID in %q, expected libraryID:/path", value)
+	}
+
+	if !strings.HasPrefix(remotePath, "/") {
+		remotePath = "/" + remotePath
+	}
+
+	return libraryID, remotePath, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// transferMetrics counts what a run transferred, for the summary and the -metrics-addr endpoint. It is
+// shared by the workers of all accounts.
+type transferMetrics struct {
+	bytes      atomic.Int64
+	downloads  atomic.Int64
+	errors     atomic.Int64
+	inProgress atomic.Int64
+}
+
+// started marks a library download as in progress
+func (m *transferMetrics) started() {
+	m.inProgress.Add(1)
+}
+
+// finished records the outcome of a library download that was started before
+func (m *transferMetrics) finished(stats seafile.Stats, err error) {
+	m.inProgress.Add(-1)
+	m.bytes.Add(stats.Bytes)
+	if err != nil {
+		m.errors.Add(1)
+	} else {
+		m.downloads.Add(1)
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *transferMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	writeMetric(w, "seafile_client_downloaded_bytes_total", "counter", "Bytes downloaded from the server.", m.bytes.Load())
+	writeMetric(w, "seafile_client_downloads_total", "counter", "Libraries downloaded successfully.", m.downloads.Load())
+	writeMetric(w, "seafile_client_download_errors_total", "counter", "Libraries that failed to download.", m.errors.Load())
+	writeMetric(w, "seafile_client_downloads_in_progress", "gauge", "Libraries being downloaded.", m.inProgress.Load())
+}
+
+func writeMetric(w http.ResponseWriter, name, kind, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
+
+// serveMetrics serves the metrics on addr until stop is called, which waits for running scrapes to finish
+func serveMetrics(addr string, m *transferMetrics) (stop func(), err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Metrics server failed", "addr", addr, "error", err)
+		}
+	}()
+	slog.Debug("Serving metrics", "url", "http://"+listener.Addr().String()+"/metrics")
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Warn("Unable to stop the metrics server", "error", err)
+		}
+	}, nil
+}
+
+// formatRate returns the throughput of transferring bytes in elapsed in the units of formatBytes
+func formatRate(bytes int64, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return formatBytes(0) + "/s"
+	}
+
+	return formatBytes(int64(float64(bytes)/elapsed.Seconds())) + "/s"
+}
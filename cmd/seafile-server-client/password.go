@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	passwordSourceConfig  = "config"
+	passwordSourceKeyring = "keyring"
+
+	// keyringService is the service the password is stored under in the OS keyring, with the username as user
+	keyringService = "seafile-server-client"
+)
+
+// resolvePassword fills in the password of c from, in this order: the environment, the OS keyring (with
+// password_source = keyring), password_file and finally the password key itself
+func resolvePassword(c *Configuration) error {
+	if value, ok := os.LookupEnv(envPassword); ok && len(value) > 0 {
+		c.Password = value
+		return nil
+	}
+
+	if c.PasswordSource == passwordSourceKeyring && len(c.Username) > 0 {
+		password, err := keyring.Get(keyringService, c.Username)
+		if err == nil {
+			c.Password = password
+			return nil
+		}
+		if !errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("unable to read the password from the keyring: %w", err)
+		}
+		slog.Debug("No password in the keyring", "service", keyringService, "user", c.Username)
+	}
+
+	if len(c.PasswordFile) > 0 {
+		data, err := ioutil.ReadFile(c.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("unable to read \"password_file\": %w", err)
+		}
+		c.Password = strings.TrimSpace(string(data))
+	}
+
+	return nil
+}
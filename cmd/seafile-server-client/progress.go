@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+const (
+	progressBarWidth    = 30
+	progressBarInterval = 200 * time.Millisecond
+	progressLogInterval = 10 * time.Second
+)
+
+// newProgressReporter draws a live bar when stderr is a terminal and logs periodically otherwise
+func newProgressReporter() seafile.ProgressReporter {
+	if isTerminal(os.Stderr) {
+		return &barProgress{throttle: newThrottle(progressBarInterval)}
+	}
+
+	return &logProgress{throttle: newThrottle(progressLogInterval)}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+type barProgress struct {
+	*throttle
+}
+
+func (p *barProgress) Progress(name string, done, total int64) {
+	if p.allow(name) {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s", formatProgress(name, done, total))
+	}
+}
+
+func (p *barProgress) Finish(name string, done, total int64) {
+	p.forget(name)
+	fmt.Fprintf(os.Stderr, "\r\033[K%s\n", formatProgress(name, done, total))
+}
+
+func (p *barProgress) FileProgress(name string, done, total int) {
+	if p.allow(name) {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s", formatFileProgress(name, done, total))
+	}
+}
+
+func (p *barProgress) FinishFiles(name string, done, total int) {
+	p.forget(name)
+	fmt.Fprintf(os.Stderr, "\r\033[K%s\n", formatFileProgress(name, done, total))
+}
+
+type logProgress struct {
+	*throttle
+}
+
+func (p *logProgress) Progress(name string, done, total int64) {
+	if p.allow(name) {
+		slog.Info("Downloading", "progress", formatProgress(name, done, total))
+	}
+}
+
+func (p *logProgress) Finish(name string, done, total int64) {
+	p.forget(name)
+	slog.Info("Downloaded", "progress", formatProgress(name, done, total))
+}
+
+func (p *logProgress) FileProgress(name string, done, total int) {
+	if p.allow(name) {
+		slog.Info("Synchronizing", "progress", formatFileProgress(name, done, total))
+	}
+}
+
+func (p *logProgress) FinishFiles(name string, done, total int) {
+	p.forget(name)
+	slog.Info("Synchronized", "progress", formatFileProgress(name, done, total))
+}
+
+// formatFileProgress renders the progress in files, e.g. "file 34 of 1200"; a total of 0 means it is unknown
+func formatFileProgress(name string, done, total int) string {
+	if total <= 0 {
+		return fmt.Sprintf("%s: file %d", name, done)
+	}
+
+	return fmt.Sprintf("%s: %s (file %d of %d)", name, progressBar(float64(done)/float64(total)), done, total)
+}
+
+func formatProgress(name string, done, total int64) string {
+	if total <= 0 {
+		return fmt.Sprintf("%s: %d bytes", name, done)
+	}
+
+	return fmt.Sprintf("%s: %s (%d/%d bytes)", name, progressBar(float64(done)/float64(total)), done, total)
+}
+
+// progressBar renders a fraction as bar followed by the percentage
+func progressBar(fraction float64) string {
+	if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction * progressBarWidth)
+
+	return fmt.Sprintf("[%s%s] %5.1f%%", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), fraction*100)
+}
+
+// throttle limits how often progress is reported for each download
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+func newThrottle(interval time.Duration) *throttle {
+	return &throttle{interval: interval, last: make(map[string]time.Time)}
+}
+
+func (t *throttle) allow(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := t.last[name]; ok && now.Sub(last) < t.interval {
+		return false
+	}
+	t.last[name] = now
+	return true
+}
+
+func (t *throttle) forget(name string) {
+	t.mu.Lock()
+	delete(t.last, name)
+	t.mu.Unlock()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+	"golang.org/x/term"
+)
+
+// authenticate gets a token, supplying a two-factor code when the server asks for one. The code is taken
+// from the flag, then the configuration, and is finally prompted for when stdin is a terminal.
+func authenticate(ctx context.Context, client *seafile.Client, c *Configuration, otp string) error {
+	if len(otp) == 0 {
+		otp = c.OTP
+	}
+
+	err := client.Authenticate(ctx, c.Username, c.Password, otp)
+	if !errors.Is(err, seafile.ErrOTPRequired) || len(otp) > 0 {
+		return err
+	}
+
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("%w: set \"otp\" in the configuration file or use -otp", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Two-factor authentication code for %s: ", c.Username)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	return client.Authenticate(ctx, c.Username, c.Password, strings.TrimSpace(line))
+}
+
+// promptPassword asks for the password of the account of c on the terminal, without echoing it. Outside
+// a terminal there is nobody to ask, so the password has to be configured.
+func promptPassword(c *Configuration) error {
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) {
+		return fmt.Errorf("missing \"password\": set it in the configuration file or via %s", envPassword)
+	}
+
+	account := c.Username + " at " + c.ApiUrl
+	if len(c.Name) > 0 {
+		account += " (account " + c.Name + ")"
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for %s: ", account)
+	password, err := term.ReadPassword(stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	c.Password = string(password)
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal; anything but an explicit yes counts as no
+func confirm(question string) bool {
+	if !isTerminal(os.Stdin) {
+		return false
+	}
+
+	fmt.Fprint(os.Stderr, question+" [y/N]: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+const (
+	reportStatusSuccess = "success"
+	reportStatusFailure = "failure"
+	reportStatusPartial = "partial"
+	reportStatusSkipped = "unchanged"
+	reportStatusEmpty   = "empty"
+)
+
+type libraryReport struct {
+	Account  string  `json:"account,omitempty"`
+	Id       string  `json:"id"`
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Files    int     `json:"files"`
+	Bytes    int64   `json:"bytes"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type runReport struct {
+	Started   time.Time       `json:"started"`
+	Duration  float64         `json:"duration_seconds"`
+	Succeeded int             `json:"succeeded"`
+	Skipped   int             `json:"skipped"`
+	Empty     int             `json:"empty"`
+	Failed    int             `json:"failed"`
+	Partial   int             `json:"partial"`
+	Files     int             `json:"files"`
+	Bytes     int64           `json:"bytes"`
+	Libraries []libraryReport `json:"libraries"`
+}
+
+func newRunReport(start time.Time, results []libraryResult) runReport {
+	report := runReport{
+		Started:   start,
+		Duration:  time.Since(start).Seconds(),
+		Libraries: make([]libraryReport, 0, len(results)),
+	}
+
+	for _, result := range results {
+		entry := libraryReport{
+			Account:  result.Account,
+			Id:       result.Library.Id,
+			Name:     result.Library.Name,
+			Status:   reportStatusSuccess,
+			Files:    result.Stats.Files,
+			Bytes:    result.Stats.Bytes,
+			Duration: result.Duration.Seconds(),
+		}
+		var partial *seafile.PartialError
+		if errors.As(result.Err, &partial) {
+			entry.Status = reportStatusPartial
+			entry.Error = result.Err.Error()
+			report.Partial++
+		} else if result.Err != nil {
+			entry.Status = reportStatusFailure
+			entry.Error = result.Err.Error()
+			report.Failed++
+		} else if result.Skipped {
+			entry.Status = reportStatusSkipped
+			report.Skipped++
+		} else if result.Empty {
+			entry.Status = reportStatusEmpty
+			report.Empty++
+		} else {
+			report.Succeeded++
+		}
+
+		report.Files += entry.Files
+		report.Bytes += entry.Bytes
+		report.Libraries = append(report.Libraries, entry)
+	}
+
+	return report
+}
+
+// writeReport writes the report to path; it is written to a temporary file first, so a collector
+// never reads a half-written report
+func writeReport(path string, report runReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + seafile.TempSuffix
+	err = ioutil.WriteFile(tmpPath, append(data, '\n'), os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
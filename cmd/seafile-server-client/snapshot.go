@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+const (
+	// snapshotLayout names the snapshot directories; it sorts chronologically
+	snapshotLayout = "2006-01-02_150405"
+	// latestSnapshot points at the newest complete snapshot: a symbolic link, or a file containing its name
+	// where symbolic links can't be created (e.g. on Windows without the privilege)
+	latestSnapshot = "latest"
+)
+
+// snapshotDir returns the directory below root the snapshot of a run started at start is stored in
+func snapshotDir(root string, start time.Time) string {
+	return filepath.Join(root, start.Format(snapshotLayout))
+}
+
+// listSnapshots returns the names of the snapshot directories in root, oldest first
+func listSnapshots(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if _, err := time.Parse(snapshotLayout, entry.Name()); err == nil && entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// finishSnapshot points latestSnapshot at the snapshot in dir and removes the oldest ones beyond keep
+// (if positive). It is only called for complete snapshots, so a failed run never pushes a good one out.
+func finishSnapshot(c *Configuration, dir string) error {
+	root, name := filepath.Dir(dir), filepath.Base(dir)
+
+	if c.SnapshotLink {
+		if err := linkLatest(root, name); err != nil {
+			return fmt.Errorf("unable to update %s: %w", latestSnapshot, err)
+		}
+	}
+
+	if c.KeepSnapshots <= 0 {
+		return nil
+	}
+
+	names, err := listSnapshots(root)
+	if err != nil {
+		return err
+	}
+
+	for len(names) > c.KeepSnapshots {
+		if names[0] != name {
+			slog.Info("Removing old snapshot", "account", c.Name, "snapshot", names[0])
+			if err = os.RemoveAll(filepath.Join(root, names[0])); err != nil {
+				return err
+			}
+		}
+		names = names[1:]
+	}
+
+	return nil
+}
+
+// linkLatest replaces latestSnapshot in root by a relative link to the snapshot name, falling back to a file
+// containing the name. The new one is created next to it first, so there always is a latestSnapshot.
+func linkLatest(root, name string) error {
+	path := filepath.Join(root, latestSnapshot)
+	tmpPath := path + seafile.TempSuffix
+	os.Remove(tmpPath)
+
+	if err := os.Symlink(name, tmpPath); err != nil {
+		slog.Debug("Unable to create a symbolic link, writing a pointer file instead", "path", path, "error", err)
+		if err = ioutil.WriteFile(tmpPath, []byte(name+"\n"), os.FileMode(0644)); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// starredDirectory is the subdirectory of the output directory that starred files are downloaded into
+const starredDirectory = "starred"
+
+// downloadStarred downloads the starred files of the account into the starred directory, grouped into a
+// subdirectory per library. Starred directories are skipped. It returns the number of files downloaded.
+func downloadStarred(ctx context.Context, client *seafile.Client, c *Configuration) (int, error) {
+	starred, err := client.ListStarredFiles(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list starred files: %w", err)
+	}
+
+	libraries, err := client.ListLibraries(ctx, c.libraryTypes()...)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list libraries: %w", err)
+	}
+	dirNames := seafile.LibraryDirNames(libraries)
+
+	downloaded, failed := 0, 0
+	for _, file := range starred {
+		if err = ctx.Err(); err != nil {
+			return downloaded, err
+		}
+
+		if file.Dir {
+			slog.Info("Skipping starred directory", "library", file.LibraryName, "path", file.Path)
+			continue
+		}
+
+		// Starred files of libraries that are shared with the account may not be listed
+		libraryDir, ok := dirNames[file.LibraryId]
+		if !ok {
+			libraryDir = file.LibraryId
+		}
+
+		// Cleaning the rooted path keeps it within the library's directory
+		localPath := filepath.Join(c.OutputDirectory, starredDirectory, libraryDir, filepath.FromSlash(path.Clean("/"+file.Path)))
+		if err = client.DownloadFile(ctx, file.LibraryId, file.Path, localPath); err != nil {
+			slog.Warn("Unable to download starred file", "library", libraryDir, "path", file.Path, "error", err)
+			failed++
+			continue
+		}
+		downloaded++
+
+		if err = os.Chtimes(localPath, file.ModTime(), file.ModTime()); err != nil {
+			slog.Warn("Unable to set modification time", "path", localPath, "error", err)
+		}
+	}
+
+	if failed > 0 {
+		return downloaded, fmt.Errorf("%d starred files failed to download", failed)
+	}
+
+	return downloaded, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// stateFile is stored in the output directory, so removing the output also forgets what was downloaded
+const stateFile = ".seafile-client-state.json"
+
+// syncState remembers the head commit of every library as of its last successful download
+type syncState struct {
+	Commits map[string]string `json:"commits"`
+}
+
+func statePath(outputDir string) string {
+	return filepath.Join(outputDir, stateFile)
+}
+
+// loadState reads the state of the previous run; a missing state file means nothing was downloaded yet
+func loadState(outputDir string) (*syncState, error) {
+	state := &syncState{Commits: make(map[string]string)}
+
+	data, err := ioutil.ReadFile(statePath(outputDir))
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+
+	if err = json.Unmarshal(data, state); err != nil {
+		return &syncState{Commits: make(map[string]string)}, err
+	}
+	if state.Commits == nil {
+		state.Commits = make(map[string]string)
+	}
+
+	return state, nil
+}
+
+// unchanged reports whether the library is still at the commit it was last downloaded at
+func (s *syncState) unchanged(libraryID, headCommit string) bool {
+	return len(headCommit) > 0 && s.Commits[libraryID] == headCommit
+}
+
+func (s *syncState) save(outputDir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := statePath(outputDir) + seafile.TempSuffix
+	if err = ioutil.WriteFile(tmpPath, data, os.FileMode(0644)); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, statePath(outputDir))
+}
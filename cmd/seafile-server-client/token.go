@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	tokenCacheDir  = "seafile-client"
+	tokenCacheFile = "token"
+)
+
+// tokenCachePath returns where the token of the account of c is cached. The file is named after the server
+// and the username, so a token is never sent to another server or used after switching to another user.
+func tokenCachePath(c *Configuration) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	key := sha256.Sum256([]byte(c.ApiUrl + "\n" + c.Username))
+	name := tokenCacheFile + "-" + hex.EncodeToString(key[:8])
+
+	return filepath.Join(cacheDir, tokenCacheDir, name), nil
+}
+
+// readCachedToken returns the token from a previous run, or an empty string if there is none
+func readCachedToken(c *Configuration) (string, error) {
+	path, err := tokenCachePath(c)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeCachedToken persists the token; it is a credential, so only the current user may read it
+func writeCachedToken(c *Configuration, token string) error {
+	path, err := tokenCachePath(c)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), os.FileMode(0700))
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(path, []byte(token), os.FileMode(0600))
+	if err != nil {
+		return err
+	}
+
+	// WriteFile does not change the mode of an existing file
+	return os.Chmod(path, os.FileMode(0600))
+}
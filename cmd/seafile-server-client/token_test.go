@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestCachedTokenIsKeyedOnServerAndUser(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cached := &Configuration{ApiUrl: "https://a.example.com/api2", Username: "me@example.com"}
+	if err := writeCachedToken(cached, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		c    *Configuration
+		want string
+	}{
+		{"same account", &Configuration{ApiUrl: cached.ApiUrl, Username: cached.Username}, "secret"},
+		{"other server", &Configuration{ApiUrl: "https://b.example.com/api2", Username: cached.Username}, ""},
+		{"other user", &Configuration{ApiUrl: cached.ApiUrl, Username: "you@example.com"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := readCachedToken(test.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != test.want {
+				t.Errorf("got token %q, want %q", token, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTraceBody limits how much of a body -trace-bodies logs
+const maxTraceBody = 4 * 1024
+
+// redactedFields are form fields and JSON keys whose values are never logged
+var redactedFields = []string{"password", "passwd", "token"}
+
+var jsonSecret = regexp.MustCompile(`"(` + strings.Join(redactedFields, "|") + `)"\s*:\s*"[^"]*"`)
+
+// traceTransport logs every request and response: method, URL, status and the names of the headers, and with
+// bodies set also the beginning of textual bodies. Passwords and tokens are redacted.
+type traceTransport struct {
+	next   http.RoundTripper
+	bodies bool
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	args := []any{"method", req.Method, "url", req.URL.Redacted(), "headers", headerNames(req.Header)}
+	if t.bodies && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := ioutil.ReadAll(io.LimitReader(body, maxTraceBody+1))
+			body.Close()
+			args = append(args, "body", redactBody(req.Header.Get("Content-Type"), data))
+		}
+	}
+	traceLogger.Info("HTTP request", args...)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		traceLogger.Info("HTTP request failed", "method", req.Method, "url", req.URL.Redacted(), "duration", time.Since(start), "error", err)
+		return resp, err
+	}
+
+	args = []any{"method", req.Method, "url", req.URL.Redacted(), "status", resp.StatusCode, "duration", time.Since(start),
+		"headers", headerNames(resp.Header)}
+	if t.bodies && textual(resp.Header.Get("Content-Type")) {
+		// Only the beginning is read here; the rest is still streamed to the caller
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxTraceBody+1))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		args = append(args, "body", redactBody(resp.Header.Get("Content-Type"), data))
+	}
+	traceLogger.Info("HTTP response", args...)
+
+	return resp, nil
+}
+
+// headerNames returns the sorted names of the headers; their values may contain secrets
+func headerNames(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// textual reports whether a body of the content type is worth logging, unlike e.g. a zip
+func textual(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") || strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+// redactBody returns data for the log with passwords and tokens replaced, cut off after maxTraceBody bytes
+func redactBody(contentType string, data []byte) string {
+	truncated := len(data) > maxTraceBody
+	if truncated {
+		data = data[:maxTraceBody]
+	}
+
+	body := string(data)
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(body); err == nil {
+			for _, field := range redactedFields {
+				if form.Has(field) {
+					form.Set(field, "REDACTED")
+				}
+			}
+			body = form.Encode()
+		}
+	} else if textual(contentType) {
+		body = jsonSecret.ReplaceAllString(body, `"$1":"REDACTED"`)
+	} else {
+		return "(not logged, " + contentType + ")"
+	}
+
+	if truncated {
+		body += "... (truncated)"
+	}
+	return body
+}
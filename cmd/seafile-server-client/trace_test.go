@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceIsLoggedWhenQuiet(t *testing.T) {
+	defaultLogger, defaultTraceLogger := slog.Default(), traceLogger
+	defer func() {
+		slog.SetDefault(defaultLogger)
+		traceLogger = defaultTraceLogger
+	}()
+
+	var logs bytes.Buffer
+	// -quiet sets log_level to error
+	setupLogging(&logs, "error", false)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &traceTransport{next: http.DefaultTransport}}
+	resp, err := client.Get(server.URL + "/api2/repos/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	slog.Info("Library downloaded")
+
+	for _, want := range []string{`msg="HTTP request"`, `msg="HTTP response"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("the log lacks %s:\n%s", want, logs.String())
+		}
+	}
+	if strings.Contains(logs.String(), "Library downloaded") {
+		t.Errorf("-quiet still logs at info level:\n%s", logs.String())
+	}
+}
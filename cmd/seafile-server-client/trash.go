@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+const trashDateFormat = "2006-01-02"
+
+// parseTrashDate parses a date given on the command line; an empty value means no bound
+func parseTrashDate(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+
+	date, err := time.ParseInLocation(trashDateFormat, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+
+	return date, nil
+}
+
+// filterTrash keeps the entries deleted on or after after and before before; zero times don't limit
+func filterTrash(entries []seafile.TrashEntry, after, before time.Time) []seafile.TrashEntry {
+	var filtered []seafile.TrashEntry
+	for _, entry := range entries {
+		if !after.IsZero() && entry.Deleted.Before(after) {
+			continue
+		}
+		if !before.IsZero() && !entry.Deleted.Before(before) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+
+	return filtered
+}
+
+// latestDeletion returns the most recently deleted entry with the given path, if any
+func latestDeletion(entries []seafile.TrashEntry, entryPath string) (seafile.TrashEntry, bool) {
+	var (
+		latest seafile.TrashEntry
+		found  bool
+	)
+
+	for _, entry := range entries {
+		if entry.Path() == entryPath && (!found || entry.Deleted.After(latest.Deleted)) {
+			latest, found = entry, true
+		}
+	}
+
+	return latest, found
+}
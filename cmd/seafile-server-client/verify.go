@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// verifyManifests checks every manifest below outputDir and writes a line per mismatching file to w. It
+// returns the number of files checked and the number of mismatches.
+func verifyManifests(ctx context.Context, w io.Writer, outputDir string) (checked, failed int, err error) {
+	manifests := 0
+	err = filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != seafile.ManifestName {
+			return nil
+		}
+
+		manifests++
+		dir := filepath.Dir(path)
+		n, mismatches, err := seafile.VerifyManifest(ctx, dir)
+		checked += n
+		failed += len(mismatches)
+		for _, mismatch := range mismatches {
+			fmt.Fprintf(w, "FAILED %s: %s\n", filepath.Join(dir, filepath.FromSlash(mismatch.Path)), mismatch.Problem)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to verify %s: %w", path, err)
+		}
+
+		return nil
+	})
+	if err == nil && manifests == 0 {
+		err = fmt.Errorf("no %s found in %s, set manifest = true and download first", seafile.ManifestName, outputDir)
+	}
+
+	return checked, failed, err
+}
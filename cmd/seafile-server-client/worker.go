@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// errLibraryEmpty is returned by processLibrary for a library without files, which counts as a success
+var errLibraryEmpty = errors.New("library is empty")
+
+type libraryResult struct {
+	// Account is the name of the account the library belongs to, if there are several
+	Account string
+	Library seafile.Library
+	// Skipped is set when the library didn't change since it was last downloaded
+	Skipped bool
+	// Empty is set when the library has no files, so there was nothing to download
+	Empty    bool
+	Stats    seafile.Stats
+	Duration time.Duration
+	Err      error
+}
+
+// downloadLibraries downloads all libraries using c.Concurrency workers, each into its directory according
+// to c.Layout. A failing library does not stop the others; the results of all libraries are returned once
+// every library has been processed.
+func downloadLibraries(ctx context.Context, client *seafile.Client, c *Configuration, libraries []seafile.Library,
+	dirNames map[string]string, metrics *transferMetrics) []libraryResult {
+	workers := c.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result []libraryResult
+		queued = make(chan seafile.Library)
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for library := range queued {
+				start := time.Now()
+				metrics.started()
+				libraryDir := libraryDirectory(c, library, dirNames)
+				stats, err := processLibrary(ctx, client, c, library, libraryDir)
+				empty := errors.Is(err, errLibraryEmpty) || (err == nil && c.SyncMode == syncModeFull && stats.Files == 0)
+				if empty {
+					err = nil
+				}
+				// An empty library isn't written as an archive, so SEAFILE_LIBRARY_PATH would point at nothing
+				archived := c.OutputFormat != string(seafile.FormatFiles)
+				if err == nil && !(empty && archived) && len(c.PostDownloadHook) > 0 {
+					err = runHook(ctx, "post_download_hook", c.PostDownloadHook, libraryHookEnv(c, library, libraryDir))
+				}
+				duration := time.Since(start)
+				metrics.finished(stats, err)
+
+				// slog writes every record in a single call, so lines from different workers won't mix
+				var partial *seafile.PartialError
+				if errors.As(err, &partial) {
+					slog.Warn("Library only partially downloaded", "library", library.Name, "files", stats.Files,
+						"failed", partial.Failed, "error", partial.Err)
+				} else if err != nil {
+					slog.Warn("Unable to download library", "library", library.Name, "error", err)
+				} else if empty {
+					slog.Info("Library is empty, skipped", "library", library.Name)
+				} else {
+					slog.Info("Downloaded library", "library", library.Name, "files", stats.Files,
+						"size", formatBytes(stats.Bytes), "library_size", formatBytes(library.Size),
+						"duration", duration.Round(time.Millisecond), "rate", formatRate(stats.Bytes, duration))
+				}
+
+				mu.Lock()
+				result = append(result, libraryResult{Library: library, Empty: empty, Stats: stats, Duration: duration, Err: err})
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, library := range libraries {
+		if ctx.Err() != nil {
+			slog.Warn("Interrupted, not downloading remaining libraries")
+			break
+		}
+		queued <- library
+	}
+	close(queued)
+
+	wg.Wait()
+	return result
+}
+
+func processLibrary(ctx context.Context, client *seafile.Client, c *Configuration, library seafile.Library,
+	libraryDir string) (seafile.Stats, error) {
+	if library.Encrypted {
+		password, ok := libraryPassword(c, library)
+		if !ok {
+			return seafile.Stats{}, fmt.Errorf("library is encrypted, but no password is configured in the [passwords] section")
+		}
+
+		if err := client.UnlockLibrary(ctx, library.Id, password); err != nil {
+			return seafile.Stats{}, fmt.Errorf("unable to unlock encrypted library: %w", err)
+		}
+	}
+
+	if c.SyncMode == syncModeIncremental {
+		stats, err := client.SyncLibrary(ctx, library, libraryDir)
+		if err != nil || !client.KeepZip {
+			return stats, err
+		}
+
+		dlLink, err := client.RequestDownloadLink(ctx, library.Id, "/")
+		if errors.Is(err, seafile.ErrEmptyDirectory) {
+			return stats, nil
+		} else if err != nil {
+			return stats, fmt.Errorf("unable to request download link: %w", err)
+		}
+
+		zipStats, err := client.SaveLibraryZip(ctx, library, dlLink, libraryDir)
+		stats.Bytes += zipStats.Bytes
+		return stats, err
+	}
+
+	// Without a link there is nothing to download; the error is reported with the library's result
+	dlLink, err := client.RequestDownloadLink(ctx, library.Id, "/")
+	if errors.Is(err, seafile.ErrEmptyDirectory) {
+		// Extracted libraries get their directory all the same; there is no archive to store, though
+		if c.OutputFormat == string(seafile.FormatFiles) {
+			if err = os.MkdirAll(libraryDir, os.FileMode(0755)); err != nil {
+				return seafile.Stats{}, err
+			}
+		}
+		return seafile.Stats{}, errLibraryEmpty
+	} else if err != nil {
+		return seafile.Stats{}, fmt.Errorf("unable to request download link: %w", err)
+	}
+
+	return client.DownloadLibrary(ctx, library, dlLink, libraryDir)
+}
+
+// libraryDirectory returns where a library is stored, within its own output directory if it has one. With
+// the flat layout, archives are still named after the library, as they can't share a file.
+func libraryDirectory(c *Configuration, library seafile.Library, dirNames map[string]string) string {
+	output := libraryOutput(c, library)
+
+	switch c.Layout {
+	case layoutPerId:
+		return filepath.Join(output, library.Id)
+	case layoutFlat:
+		if c.OutputFormat == string(seafile.FormatFiles) {
+			return output
+		}
+	}
+
+	return filepath.Join(output, dirNames[library.Id])
+}
+
+// formatBytes renders a size in bytes in human readable form, e.g. 1.2 GB
+func formatBytes(bytes int64) string {
+	const unit = 1000
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "kMGTPE"[exp])
+}
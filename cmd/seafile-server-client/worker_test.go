@@ -0,0 +1,201 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EtienneBruines/seafile-server-client/seafile"
+)
+
+// serveLibraryZip writes a zip with a readme.txt containing content
+func serveLibraryZip(t *testing.T, w http.ResponseWriter, content string) {
+	zw := zip.NewWriter(w)
+	entry, err := zw.Create("readme.txt")
+	if err == nil {
+		_, err = entry.Write([]byte(content))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLibrariesWithTheSameFileDoNotCollide(t *testing.T) {
+	// Every library has a readme.txt containing the ID of the library
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveLibraryZip(t, w, strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		layout    string
+		libraries []seafile.Library
+	}{
+		{layoutPerLibrary, []seafile.Library{{Id: "aaaaaaaa-1", Name: "Work"}, {Id: "bbbbbbbb-2", Name: "Home"}}},
+		{layoutPerLibrary, []seafile.Library{{Id: "aaaaaaaa-1", Name: "Docs"}, {Id: "bbbbbbbb-2", Name: "docs"}}},
+		{layoutPerId, []seafile.Library{{Id: "aaaaaaaa-1", Name: "Docs"}, {Id: "bbbbbbbb-2", Name: "Docs"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.layout+" "+test.libraries[0].Name, func(t *testing.T) {
+			c := defaultConfiguration()
+			c.OutputDirectory = t.TempDir()
+			c.Layout = test.layout
+
+			client := seafile.NewClient(server.URL + "/api2")
+			client.OutputFormat = seafile.FormatFiles
+			client.TempDir = t.TempDir()
+
+			dirNames := seafile.LibraryDirNames(test.libraries)
+			for _, library := range test.libraries {
+				dir := libraryDirectory(c, library, dirNames)
+				if _, err := client.DownloadLibrary(context.Background(), library, server.URL+"/"+library.Id, dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, library := range test.libraries {
+				data, err := os.ReadFile(filepath.Join(libraryDirectory(c, library, dirNames), "readme.txt"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != library.Id {
+					t.Errorf("readme.txt of %s contains %q, it was overwritten", library.Name, data)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadLibrariesContinuesWhenALinkFails(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/repos/broken/dir/download/":
+			http.Error(w, `{"error_msg": "Internal Server Error"}`, http.StatusInternalServerError)
+		case "/files/broken":
+			t.Error("downloaded the library whose link couldn't be resolved")
+		default:
+			if id, ok := strings.CutPrefix(r.URL.Path, "/files/"); ok {
+				serveLibraryZip(t, w, id)
+				return
+			}
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api2/repos/"), "/dir/download/")
+			fmt.Fprintf(w, "%q", server.URL+"/files/"+id)
+		}
+	}))
+	defer server.Close()
+
+	c := defaultConfiguration()
+	c.OutputDirectory = t.TempDir()
+	c.Concurrency = 2
+
+	client := seafile.NewClient(server.URL + "/api2")
+	client.OutputFormat = seafile.FormatFiles
+	client.TempDir = t.TempDir()
+	client.MaxRetries = 0
+
+	libraries := []seafile.Library{{Id: "first", Name: "First"}, {Id: "broken", Name: "Broken"}, {Id: "last", Name: "Last"}}
+	results := downloadLibraries(context.Background(), client, c, libraries, seafile.LibraryDirNames(libraries), &transferMetrics{})
+
+	if len(results) != len(libraries) {
+		t.Fatalf("got %d results, want one for each of the %d libraries", len(results), len(libraries))
+	}
+	for _, result := range results {
+		if result.Library.Id == "broken" {
+			if result.Err == nil || !strings.Contains(result.Err.Error(), "unable to request download link") {
+				t.Errorf("expected the link failure to be recorded, got %v", result.Err)
+			}
+			continue
+		}
+
+		if result.Err != nil || result.Stats.Files != 1 {
+			t.Errorf("library %s: got %d files and error %v, want it downloaded", result.Library.Name, result.Stats.Files, result.Err)
+		}
+	}
+}
+
+func TestDownloadLibrariesSkipsEmptyLibraries(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/repos/empty-zip/dir/download/":
+			fmt.Fprintf(w, "%q", server.URL+"/files/empty-zip")
+		case "/files/empty-zip":
+			if err := zip.NewWriter(w).Close(); err != nil {
+				t.Error(err)
+			}
+		case "/api2/repos/refused/dir/download/":
+			// Some servers refuse to zip a library without files
+			http.Error(w, `{"error_msg": "Unable to download directory"}`, http.StatusBadRequest)
+		case "/api2/repos/refused/dir/":
+			w.Write([]byte("[]"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		format seafile.OutputFormat
+		// wantHook is whether post_download_hook runs, which needs something at SEAFILE_LIBRARY_PATH
+		wantHook bool
+	}{
+		{seafile.FormatFiles, true},
+		{seafile.FormatZip, false},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			c := defaultConfiguration()
+			c.OutputDirectory = t.TempDir()
+			c.OutputFormat = string(test.format)
+			hookRan := filepath.Join(t.TempDir(), "hook-ran")
+			c.PostDownloadHook = "touch '" + hookRan + "'"
+
+			client := seafile.NewClient(server.URL + "/api2")
+			client.OutputFormat = test.format
+			client.TempDir = t.TempDir()
+
+			libraries := []seafile.Library{{Id: "empty-zip", Name: "Empty zip"}, {Id: "refused", Name: "Refused"}}
+			dirNames := seafile.LibraryDirNames(libraries)
+			results := downloadLibraries(context.Background(), client, c, libraries, dirNames, &transferMetrics{})
+
+			report := newRunReport(time.Now(), results)
+			if report.Empty != len(libraries) || report.Succeeded != 0 || report.Failed != 0 {
+				t.Errorf("report counts %d empty, %d succeeded and %d failed, want all empty", report.Empty, report.Succeeded, report.Failed)
+			}
+
+			for _, result := range results {
+				if result.Err != nil || !result.Empty {
+					t.Errorf("library %s: got error %v and empty %v, want it skipped as empty", result.Library.Name, result.Err, result.Empty)
+				}
+				if test.format != seafile.FormatFiles {
+					// Nothing is written for an empty library in an archive format
+					continue
+				}
+				if info, err := os.Stat(libraryDirectory(c, result.Library, dirNames)); err != nil || !info.IsDir() {
+					t.Errorf("library %s has no directory: %v", result.Library.Name, err)
+				}
+			}
+			if _, err := os.Stat(hookRan); (err == nil) != test.wantHook {
+				t.Errorf("post_download_hook ran: %v, want %v", err == nil, test.wantHook)
+			}
+			for _, entry := range report.Libraries {
+				if entry.Status != reportStatusEmpty {
+					t.Errorf("library %s has status %q in the report, want %q", entry.Name, entry.Status, reportStatusEmpty)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+const pathAccountInfo = "/account/info/"
+
+// AccountInfo describes the account the client is authenticated as. Sizes are in bytes; a negative
+// Total means the space is unlimited.
+type AccountInfo struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Usage int64  `json:"usage"`
+	Total int64  `json:"total"`
+}
+
+// Unlimited reports whether the account has no quota
+func (a AccountInfo) Unlimited() bool {
+	return a.Total < 0
+}
+
+// AccountInfo returns the email address and space usage of the account
+func (c *Client) AccountInfo(ctx context.Context) (AccountInfo, error) {
+	var info AccountInfo
+
+	req, err := c.newRequest(ctx, "GET", pathAccountInfo, nil)
+	if err != nil {
+		return info, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return info, ErrUnauthorized
+	}
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return info, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return info, err
+	}
+
+	err = json.Unmarshal(body, &info)
+	return info, err
+}
@@ -0,0 +1,338 @@
+package seafile
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zip"
+)
+
+// OutputFormat determines what is written for every downloaded library
+type OutputFormat string
+
+const (
+	// FormatFiles extracts the library into the output directory
+	FormatFiles OutputFormat = "files"
+	// FormatTarGz stores the library as <name>.tar.gz in the output directory
+	FormatTarGz OutputFormat = "tar.gz"
+	// FormatZip stores the zip as sent by the server as <name>.zip in the output directory
+	FormatZip OutputFormat = "zip"
+)
+
+// Compression levels of Client.CompressionLevel
+const (
+	// NoCompression only stores the files, which is fastest and loses little for already compressed media
+	NoCompression = flate.NoCompression
+	// BestSpeed and BestCompression are the range of levels, trading CPU time for smaller archives
+	BestSpeed       = flate.BestSpeed
+	BestCompression = flate.BestCompression
+	// DefaultCompression is a good compromise, equivalent to level 6
+	DefaultCompression = flate.DefaultCompression
+)
+
+// storeZip stores the downloaded archive in the format of c.OutputFormat and returns the number of files
+// it contains: extracted into extractDir, or as archivePath plus the extension of the format. With c.KeepZip,
+// the zip is kept next to the other formats.
+func (c *Client) storeZip(ctx context.Context, downloadedPath, archivePath, extractDir string) (int, error) {
+	if c.KeepZip && c.OutputFormat != FormatZip {
+		if err := copyFile(downloadedPath, archivePath+".zip"); err != nil {
+			return 0, fmt.Errorf("unable to keep zip: %w", err)
+		}
+	}
+
+	switch c.OutputFormat {
+	case FormatTarGz:
+		return repackTarGz(ctx, downloadedPath, archivePath+".tar.gz", c.CompressionLevel)
+	case FormatZip:
+		if c.CompressionLevel != DefaultCompression {
+			return repackZip(ctx, downloadedPath, archivePath+".zip", c.CompressionLevel)
+		}
+		files, err := countFiles(downloadedPath)
+		if err != nil {
+			return 0, err
+		}
+		return files, moveFile(downloadedPath, archivePath+".zip")
+	case FormatFiles, "":
+		files, err := c.extractZip(ctx, downloadedPath, extractDir)
+		var partial *PartialError
+		if c.ExtractNested && (err == nil || errors.As(err, &partial)) {
+			nested, nestedErr := c.extractNested(ctx, extractDir)
+			files += nested
+			err = errors.Join(err, nestedErr)
+		}
+		return files, err
+	default:
+		return 0, fmt.Errorf("unknown output format %q", c.OutputFormat)
+	}
+}
+
+func countFiles(zipPath string) (int, error) {
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer zipReader.Close()
+
+	files := 0
+	for _, file := range zipReader.File {
+		if !file.FileInfo().IsDir() {
+			files++
+		}
+	}
+
+	return files, nil
+}
+
+// repackTarGz copies all entries of the zip into a gzipped tarball, keeping their paths, modes and mtimes.
+// The tarball is compressed at level and only moved to outputPath once it is complete.
+func repackTarGz(ctx context.Context, zipPath, outputPath string, level int) (int, error) {
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer zipReader.Close()
+
+	tmpPath := outputPath + TempSuffix
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultFileMode)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	files, err := writeTarGz(ctx, out, zipReader.File, level)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return files, os.Rename(tmpPath, outputPath)
+}
+
+func writeTarGz(ctx context.Context, w io.Writer, entries []*zip.File, level int) (int, error) {
+	gzipWriter, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return 0, err
+	}
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	files := 0
+	for _, file := range entries {
+		if err := ctx.Err(); err != nil {
+			return files, err
+		}
+
+		// Paths that would escape on extraction are not archived either
+		if _, err := safeJoin(".", file.Name); err != nil {
+			return files, err
+		}
+
+		header := &tar.Header{
+			Name:    file.Name,
+			ModTime: file.Modified,
+			Mode:    int64(entryMode(file, defaultFileMode)),
+		}
+
+		if file.FileInfo().IsDir() {
+			header.Typeflag = tar.TypeDir
+			header.Mode = int64(entryMode(file, defaultDirMode))
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(file.UncompressedSize64)
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return files, err
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			if err := copyEntry(tarWriter, file); err != nil {
+				return files, fmt.Errorf("unable to archive %s: %w", file.Name, err)
+			}
+			files++
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return files, err
+	}
+
+	return files, gzipWriter.Close()
+}
+
+// repackZip copies all entries of the zip into a new zip, compressed at level or only stored with NoCompression.
+// Like repackTarGz, it is only moved to outputPath once it is complete.
+func repackZip(ctx context.Context, zipPath, outputPath string, level int) (int, error) {
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer zipReader.Close()
+
+	tmpPath := outputPath + TempSuffix
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultFileMode)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	files, err := writeZip(ctx, out, zipReader.File, level)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return files, os.Rename(tmpPath, outputPath)
+}
+
+func writeZip(ctx context.Context, w io.Writer, entries []*zip.File, level int) (int, error) {
+	zipWriter := zip.NewWriter(w)
+	zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+
+	method := zip.Deflate
+	if level == NoCompression {
+		method = zip.Store
+	}
+
+	files := 0
+	for _, file := range entries {
+		if err := ctx.Err(); err != nil {
+			return files, err
+		}
+
+		if _, err := safeJoin(".", file.Name); err != nil {
+			return files, err
+		}
+
+		header := &zip.FileHeader{Name: file.Name, Modified: file.Modified, Method: method}
+		if file.FileInfo().IsDir() {
+			header.Method = zip.Store
+			header.SetMode(entryMode(file, defaultDirMode) | os.ModeDir)
+		} else {
+			header.SetMode(entryMode(file, defaultFileMode))
+		}
+
+		entryWriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return files, err
+		}
+
+		if !file.FileInfo().IsDir() {
+			if err = copyEntry(entryWriter, file); err != nil {
+				return files, fmt.Errorf("unable to archive %s: %w", file.Name, err)
+			}
+			files++
+		}
+	}
+
+	return files, zipWriter.Close()
+}
+
+func copyEntry(w io.Writer, file *zip.File) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// moveFile renames src to dst, copying it when they are on different filesystems
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// SaveLibraryZip stores the zip behind downloadLink as libraryDir.zip without extracting it. When the
+// server reports the same ETag and size as for the zip saved before, nothing is downloaded.
+func (c *Client) SaveLibraryZip(ctx context.Context, library Library, downloadLink, libraryDir string) (Stats, error) {
+	var stats Stats
+	savedPath := libraryDir + ".zip"
+	etagPath := savedPath + ".etag"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadLink, nil)
+	if err != nil {
+		return stats, err
+	}
+
+	savedETag, _ := ioutil.ReadFile(etagPath)
+	if len(savedETag) > 0 {
+		req.Header.Set("If-None-Match", string(savedETag))
+	}
+
+	resp, err := c.doTransfer(req)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified || (len(savedETag) > 0 && resp.Header.Get("ETag") == string(savedETag) &&
+		fileSize(savedPath) == resp.ContentLength) {
+		c.logger().Debug("Saved zip is up to date", "library", library.Name, "path", savedPath)
+		return stats, nil
+	}
+
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return stats, err
+	}
+
+	os.Remove(etagPath)
+	body := &progressReader{Reader: c.throttle(ctx, resp.Body), name: library.Name, total: resp.ContentLength, reporter: c.Progress}
+	err = writeStream(savedPath, body, defaultFileMode, nil)
+	c.Progress.Finish(body.name, body.done, body.total)
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Bytes = body.done
+	if etag := resp.Header.Get("ETag"); len(etag) > 0 {
+		if err = ioutil.WriteFile(etagPath, []byte(etag), defaultFileMode); err != nil {
+			c.logger().Warn("Unable to store ETag of zip", "path", etagPath, "error", err)
+		}
+	}
+
+	stats.Files, err = countFiles(savedPath)
+	return stats, err
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return -1
+	}
+
+	return info.Size()
+}
+
+// copyFile copies src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeStream(dst, in, defaultFileMode, nil)
+}
@@ -0,0 +1,450 @@
+// Package seafile is a client for the web API (api2) of a Seafile server.
+package seafile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	apiPath    = "/api2"
+	apiV21Path = "/api/v2.1"
+
+	pathPing      = "/ping/"
+	pathAuthToken = "/auth-token/"
+	pathAuthPing  = "/auth/ping/"
+	pathLibraries = "/repos/"
+	pathDir       = "/dir/"
+	pathFile      = "/file/"
+
+	headerOTP = "X-Seafile-OTP"
+)
+
+var (
+	// ErrUnauthorized is returned when the server doesn't accept the token
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrOTPRequired is returned by Authenticate when a two-factor authentication code is needed
+	ErrOTPRequired = errors.New("two-factor authentication code required")
+	// ErrAuthenticationFailed is returned by Authenticate when the server doesn't accept the credentials
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	// ErrInvalidCredentials is returned by Authenticate when the server rejects the username and password, or
+	// the account has been locked or deactivated; it is an ErrAuthenticationFailed as well
+	ErrInvalidCredentials = fmt.Errorf("%w: invalid credentials", ErrAuthenticationFailed)
+	// ErrNotSeafile is returned by Ping and AuthPing when the URL answers, but not like a Seafile server does,
+	// e.g. because it points at a website or a login page of a reverse proxy
+	ErrNotSeafile = errors.New("not a Seafile API")
+)
+
+// maxPingBody limits how much of a ping response is read; a Seafile server answers with just "pong"
+const maxPingBody = 1024
+
+// Client talks to a single Seafile server on behalf of a single account
+type Client struct {
+	HTTPClient *http.Client
+	// TransferClient is used for downloading and uploading file contents, which can take much longer than
+	// API calls; nil means HTTPClient
+	TransferClient *http.Client
+
+	// BaseURL is the URL of the api2 endpoint, e.g. https://seafile.example.com/api2
+	BaseURL string
+	Token   string
+	// Reauthenticate returns a new token when the server refuses the current one during a run, e.g. because it
+	// expired; nil means such requests simply fail. It is called at most once per refused token.
+	Reauthenticate func(ctx context.Context) (string, error)
+	// tokenMu guards Token once requests are made concurrently, refreshMu makes concurrent requests that find
+	// the token expired wait for a single Reauthenticate
+	tokenMu   sync.RWMutex
+	refreshMu sync.Mutex
+
+	// MaxRetries is the number of times transient failures are retried, starting after RetryDelay
+	MaxRetries int
+	RetryDelay time.Duration
+	// RateLimiter limits the requests to the server, including retries; nil means unlimited. It is slowed
+	// down whenever the server answers 429 Too Many Requests.
+	RateLimiter *rate.Limiter
+	// BandwidthLimiter limits the download rate in bytes per second, of all downloads together; nil means
+	// unlimited. Its burst is the most that is read at once.
+	BandwidthLimiter *rate.Limiter
+	// Connections limits the number of requests in flight to its capacity, whether they are made for different
+	// libraries or files. A slot is held until the response body is closed. Nil means unlimited.
+	Connections chan struct{}
+
+	// Server is what GetServerInfo found out about the server; nil when it wasn't asked or couldn't tell
+	Server *ServerInfo
+
+	// FileServerURL replaces the root of the download and upload links the server hands out, for deployments
+	// where the file server is reachable at another address than the one the server advertises
+	FileServerURL string
+
+	// TempDir is where downloaded archives are buffered; empty means os.TempDir
+	TempDir string
+	// OutputFormat is how downloaded libraries are stored; empty means FormatFiles
+	OutputFormat OutputFormat
+	// ExtractWorkers is the number of files of a library that are extracted at once; zero means one per CPU,
+	// up to 8
+	ExtractWorkers int
+	// CompressionLevel is the level FormatTarGz is compressed at, from NoCompression over BestSpeed to
+	// BestCompression, or DefaultCompression. With FormatZip, the zip of the server is repacked at that
+	// level, unless it is DefaultCompression.
+	CompressionLevel int
+	// Manifest writes the SHA-256 of every extracted file to a ManifestName file in the extraction directory
+	Manifest bool
+	// ExtractNested also extracts the zip files in extracted archives, into a directory next to each, and those
+	// in there down to NestedMaxDepth levels, until they expanded to NestedMaxSize bytes. Zero limits mean
+	// DefaultNestedMaxDepth and DefaultNestedMaxSize.
+	ExtractNested  bool
+	NestedMaxDepth int
+	NestedMaxSize  int64
+	// KeepZip also stores the zip as sent by the server when OutputFormat is not FormatZip
+	KeepZip bool
+	// CountFiles makes SyncLibrary count the files of a library upfront when the server doesn't report their
+	// number, so the progress can show it. This lists all directories twice.
+	CountFiles bool
+	// MaxFileSize makes SyncLibrary skip files larger than it, if positive
+	MaxFileSize int64
+	// FileInclude and FileExclude are patterns (see MatchPath) of the files SyncLibrary downloads or leaves out;
+	// an empty FileInclude includes all files. Local files they leave out are never deleted.
+	FileInclude []string
+	FileExclude []string
+	// DeleteExtraneous makes SyncLibrary remove local entries that don't exist in the library, unless there
+	// are more than MaxDelete (if positive). With DryRun, they are only logged.
+	DeleteExtraneous bool
+	MaxDelete        int
+	DryRun           bool
+
+	Progress ProgressReporter
+	Logger   *slog.Logger
+}
+
+// NewClient returns a client for the api2 endpoint at baseURL with default settings
+func NewClient(baseURL string) *Client {
+	return &Client{
+		HTTPClient: http.DefaultClient,
+		BaseURL:    baseURL,
+		MaxRetries: 3,
+		RetryDelay: time.Second,
+		Progress:   NoopProgress{},
+		Logger:     slog.Default(),
+
+		// The zero value would only store the files
+		CompressionLevel: DefaultCompression,
+	}
+}
+
+func (c *Client) transferClient() *http.Client {
+	if c.TransferClient == nil {
+		return c.HTTPClient
+	}
+	return c.TransferClient
+}
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+
+	return c.Logger
+}
+
+// NormalizeBaseURL turns the URL of a Seafile server, e.g. as copied from the browser, into the URL of its
+// api2 endpoint. A trailing slash is removed and /api2 is appended if it is missing.
+func NormalizeBaseURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	if !strings.HasSuffix(u.Path, apiPath) {
+		u.Path += apiPath
+	}
+	u.RawPath = ""
+
+	return u.String(), nil
+}
+
+// endpoint returns the URL of an API path, which may include a query string. Paths starting with apiV21Path
+// belong to the newer API, which lives next to api2 on the server.
+func (c *Client) endpoint(path string) (string, error) {
+	query := ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, query = path[:i], path[i:]
+	}
+
+	base := c.BaseURL
+	if strings.HasPrefix(path, apiV21Path+"/") {
+		base = strings.TrimSuffix(strings.TrimRight(base, "/"), apiPath)
+	}
+
+	endpoint, err := url.JoinPath(base, path)
+	if err != nil {
+		return "", err
+	}
+
+	return endpoint + query, nil
+}
+
+// newRequest creates a request for an API path, authenticated with the token when there is one
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	endpoint, err := c.endpoint(path)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := c.currentToken(); len(token) > 0 {
+		req.Header.Add("Authorization", "Token "+token)
+	}
+
+	return req, nil
+}
+
+// newJSONRequest creates a request with v encoded as JSON body
+func (c *Client) newJSONRequest(ctx context.Context, method, path string, v interface{}) (*http.Request, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// newFormRequest creates a request with data as url-encoded form body
+func (c *Client) newFormRequest(ctx context.Context, method, path string, data url.Values) (*http.Request, error) {
+	req, err := c.newRequest(ctx, method, path, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// getLink requests an API path that responds with a quoted URL, like the download and upload links
+func (c *Client) getLink(ctx context.Context, path string) (string, error) {
+	bodyBinary, err := c.getBody(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	return c.fileServerLink(strings.Trim(string(bodyBinary), "\"")), nil
+}
+
+// getBody requests an API path and returns the body of the response, or an *APIError if it isn't a 200
+func (c *Client) getBody(ctx context.Context, path string) ([]byte, error) {
+	req, err := c.newRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, bodyBinary)
+	}
+
+	return bodyBinary, nil
+}
+
+// Ping checks whether the server is reachable and answers like Seafile, returning ErrNotSeafile if it doesn't
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := c.newRequest(ctx, "GET", pathPing, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return err
+	}
+
+	return checkPong(resp)
+}
+
+// checkPong returns ErrNotSeafile unless the body of a successful ping response is "pong"
+func checkPong(resp *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxPingBody))
+	if err != nil {
+		return err
+	}
+
+	if strings.Trim(strings.TrimSpace(string(body)), "\"") == "pong" {
+		return nil
+	}
+
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > 80 {
+		snippet = snippet[:80] + "..."
+	}
+
+	return fmt.Errorf("%w: %s answered %q (%s) instead of \"pong\", check the url", ErrNotSeafile, resp.Request.URL.Path, snippet, resp.Header.Get("Content-Type"))
+}
+
+// GetToken requests an auth token. Servers with two-factor authentication enabled (Seafile 6.0 and newer)
+// answer with a 400 and "X-Seafile-OTP: required" unless the one-time password is sent along, in which
+// case ErrOTPRequired is returned.
+func (c *Client) GetToken(ctx context.Context, username, password, otp string) (string, error) {
+	data := url.Values{}
+	data.Add("username", username)
+	data.Add("password", password)
+
+	req, err := c.newFormRequest(ctx, "POST", pathAuthToken, data)
+	if err != nil {
+		return "", err
+	}
+
+	// Never send a stale token along when requesting a new one
+	req.Header.Del("Authorization")
+	if len(otp) > 0 {
+		req.Header.Set(headerOTP, otp)
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest && strings.EqualFold(resp.Header.Get(headerOTP), "required") {
+		return "", ErrOTPRequired
+	}
+
+	// Wrong credentials, as well as locked and inactive accounts, are answered with a 400 (401 by some
+	// proxies and older versions) and the reason in non_field_errors
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCredentials, checkStatus(resp))
+	}
+
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return "", err
+	}
+
+	type AuthToken struct {
+		Token string `json:"token"`
+	}
+
+	binaryBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var authToken AuthToken
+	err = json.Unmarshal(binaryBody, &authToken)
+	if err != nil {
+		return "", fmt.Errorf("%w: unreadable token response: %v", ErrAuthenticationFailed, err)
+	}
+
+	if len(authToken.Token) == 0 {
+		return "", fmt.Errorf("%w: no token in the response", ErrAuthenticationFailed)
+	}
+
+	return authToken.Token, nil
+}
+
+// Authenticate requests a token (see GetToken) and uses it for all following requests
+func (c *Client) Authenticate(ctx context.Context, username, password, otp string) error {
+	token, err := c.GetToken(ctx, username, password, otp)
+	if err != nil {
+		return err
+	}
+
+	c.setToken(token)
+	return nil
+}
+
+func (c *Client) currentToken() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.Token
+}
+
+func (c *Client) setToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.Token = token
+}
+
+// refreshToken replaces refused, the token a request was sent with, by one from c.Reauthenticate. When another
+// request replaced it in the meantime, that token is returned instead of requesting yet another one.
+func (c *Client) refreshToken(ctx context.Context, refused string) (string, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if token := c.currentToken(); token != refused {
+		return token, nil
+	}
+
+	c.logger().Info("Auth token refused, authenticating again")
+	token, err := c.Reauthenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(token) == 0 || token == refused {
+		return "", fmt.Errorf("%w: no new token", ErrAuthenticationFailed)
+	}
+
+	c.setToken(token)
+	return token, nil
+}
+
+// AuthPing checks whether the server accepts the token, returning ErrUnauthorized if it doesn't and
+// ErrNotSeafile if the answer isn't from Seafile
+func (c *Client) AuthPing(ctx context.Context) error {
+	req, err := c.newRequest(ctx, "GET", pathAuthPing, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return err
+	}
+
+	return checkPong(resp)
+}
@@ -0,0 +1,115 @@
+package seafile
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://seafile.example.com", "https://seafile.example.com/api2"},
+		{"https://seafile.example.com/", "https://seafile.example.com/api2"},
+		{"https://seafile.example.com/api2", "https://seafile.example.com/api2"},
+		{"https://seafile.example.com/api2/", "https://seafile.example.com/api2"},
+		{"https://seafile.example.com/api2//", "https://seafile.example.com/api2"},
+		{" https://example.com/seafile/ ", "https://example.com/seafile/api2"},
+	}
+
+	for _, test := range tests {
+		got, err := NormalizeBaseURL(test.in)
+		if err != nil {
+			t.Errorf("NormalizeBaseURL(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("NormalizeBaseURL(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		base, path, want string
+	}{
+		{"https://example.com/api2", "/repos/", "https://example.com/api2/repos/"},
+		{"https://example.com/api2/", "/repos/", "https://example.com/api2/repos/"},
+		{"https://example.com/api2", "/repos/id/dir/?p=/docs", "https://example.com/api2/repos/id/dir/?p=/docs"},
+		{"https://example.com/api2/", "/api/v2.1/repos/", "https://example.com/api/v2.1/repos/"},
+	}
+
+	for _, test := range tests {
+		got, err := NewClient(test.base).endpoint(test.path)
+		if err != nil {
+			t.Errorf("endpoint(%q) on %q: %v", test.path, test.base, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("endpoint(%q) on %q = %q, want %q", test.path, test.base, got, test.want)
+		}
+	}
+}
+
+func TestGetToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		header      string
+		body        string
+		wantToken   string
+		wantErr     error
+		wantMessage string
+	}{
+		{"valid", http.StatusOK, "", `{"token": "secret"}`, "secret", nil, ""},
+		{"wrong password", http.StatusBadRequest, "", `{"non_field_errors": ["Unable to login with provided credentials."]}`,
+			"", ErrInvalidCredentials, "Unable to login with provided credentials."},
+		{"locked account", http.StatusBadRequest, "",
+			`{"non_field_errors": ["This account has been frozen due to too many failed login attempts."]}`,
+			"", ErrInvalidCredentials, "This account has been frozen"},
+		{"unauthorized", http.StatusUnauthorized, "", `{"detail": "Invalid username/password."}`,
+			"", ErrInvalidCredentials, "Invalid username/password."},
+		{"two-factor code needed", http.StatusBadRequest, "required", `{"non_field_errors": ["Two factor auth token is missing."]}`,
+			"", ErrOTPRequired, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || r.URL.Path != "/api2/auth-token/" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				if len(test.header) > 0 {
+					w.Header().Set(headerOTP, test.header)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			token, err := NewClient(server.URL+"/api2").GetToken(context.Background(), "me@example.com", "password", "")
+			if token != test.wantToken {
+				t.Errorf("got token %q, want %q", token, test.wantToken)
+			}
+			if test.wantErr == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if errors.Is(test.wantErr, ErrInvalidCredentials) && !errors.Is(err, ErrAuthenticationFailed) {
+				t.Errorf("invalid credentials must also be an authentication failure: %v", err)
+			}
+			if !strings.Contains(err.Error(), test.wantMessage) {
+				t.Errorf("error %q doesn't contain the message of the server %q", err, test.wantMessage)
+			}
+		})
+	}
+}
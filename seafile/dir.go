@@ -0,0 +1,165 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Possible values of DirEntry.Type
+const (
+	EntryTypeFile = "file"
+	EntryTypeDir  = "dir"
+)
+
+// DirEntry is a single file or directory as returned by the /dir/ endpoint
+type DirEntry struct {
+	Id    string `json:"id"`
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Mtime int64  `json:"mtime"`
+}
+
+// IsDir reports whether the entry is a directory
+func (e DirEntry) IsDir() bool {
+	return e.Type == EntryTypeDir
+}
+
+// ModTime returns the modification time of the entry
+func (e DirEntry) ModTime() time.Time {
+	return time.Unix(e.Mtime, 0)
+}
+
+// ListDirectory returns the contents of the directory at dirPath in the library
+func (c *Client) ListDirectory(ctx context.Context, libraryID, dirPath string) ([]DirEntry, error) {
+	query := url.Values{}
+	query.Set("p", dirPath)
+
+	req, err := c.newRequest(ctx, "GET", pathLibraries+libraryID+pathDir+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, bodyBinary)
+	}
+
+	var entries []DirEntry
+	err = json.Unmarshal(bodyBinary, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// RequestFileLink returns a one-time link from which the file at filePath can be downloaded
+func (c *Client) RequestFileLink(ctx context.Context, libraryID, filePath string) (string, error) {
+	query := url.Values{}
+	query.Set("p", filePath)
+
+	return c.getLink(ctx, pathLibraries+libraryID+pathFile+"?"+query.Encode())
+}
+
+// DownloadFile downloads a single file from the library to localPath
+func (c *Client) DownloadFile(ctx context.Context, libraryID, remotePath, localPath string) error {
+	link, err := c.RequestFileLink(ctx, libraryID, remotePath)
+	if err != nil {
+		return fmt.Errorf("unable to request download link: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(localPath), defaultDirMode)
+	if err != nil {
+		return err
+	}
+
+	return c.downloadToFile(ctx, link, localPath)
+}
+
+// ErrAlreadyExists is returned when creating a directory that already exists
+var ErrAlreadyExists = errors.New("already exists")
+
+// MakeDir creates the directory at dirPath in the library. With existOK, a directory that already exists
+// is not an error; a file by that name always is.
+func (c *Client) MakeDir(ctx context.Context, libraryID, dirPath string, existOK bool) error {
+	// Instead of refusing a directory that exists already, the server creates e.g. "docs (1)", so that
+	// is looked up first
+	parent, name := path.Split(path.Clean("/" + dirPath))
+	if len(name) == 0 {
+		// The root of the library
+		if existOK {
+			return nil
+		}
+		return fmt.Errorf("%s %w", dirPath, ErrAlreadyExists)
+	}
+
+	entries, err := c.ListDirectory(ctx, libraryID, parent)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name != name {
+			continue
+		}
+		if entry.IsDir() && existOK {
+			return nil
+		}
+		return fmt.Errorf("%s %w", dirPath, ErrAlreadyExists)
+	}
+
+	query := url.Values{}
+	query.Set("p", dirPath)
+
+	data := url.Values{}
+	data.Set("operation", "mkdir")
+
+	req, err := c.newFormRequest(ctx, "POST", pathLibraries+libraryID+pathDir+"?"+query.Encode(), data)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError(resp, bodyBinary)
+		if resp.StatusCode == http.StatusConflict || strings.Contains(strings.ToLower(apiErr.Message), "exist") {
+			if existOK {
+				return nil
+			}
+			return fmt.Errorf("%s %w: %v", dirPath, ErrAlreadyExists, apiErr)
+		}
+		return apiErr
+	}
+
+	return nil
+}
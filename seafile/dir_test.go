@@ -0,0 +1,58 @@
+package seafile
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMakeDir(t *testing.T) {
+	tests := []struct {
+		name        string
+		dirPath     string
+		existOK     bool
+		wantErr     error
+		wantCreated bool
+	}{
+		{"new", "/docs/new", false, nil, true},
+		{"existing", "/docs/reports", true, nil, false},
+		{"existing, not OK", "/docs/reports", false, ErrAlreadyExists, false},
+		{"file by that name", "/docs/notes.txt", true, ErrAlreadyExists, false},
+		{"root", "/", true, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			created := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api2/repos/lib-1/dir/" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+
+				switch {
+				case r.Method == "GET" && r.URL.Query().Get("p") == "/docs/":
+					w.Write([]byte(`[{"type": "dir", "name": "reports"}, {"type": "file", "name": "notes.txt"}]`))
+				case r.Method == "POST" && r.URL.Query().Get("p") == test.dirPath:
+					created = true
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`"success"`))
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.RequestURI())
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			err := NewClient(server.URL+"/api2").MakeDir(context.Background(), "lib-1", test.dirPath, test.existOK)
+			if !errors.Is(err, test.wantErr) || (test.wantErr == nil && err != nil) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+			// Creating a directory that exists makes the server create a renamed copy
+			if created != test.wantCreated {
+				t.Errorf("directory created: %v, want %v", created, test.wantCreated)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package seafile
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zip"
+)
+
+// ErrEmptyDirectory is returned by RequestDownloadLink for a directory without any entries, which some servers
+// refuse to zip
+var ErrEmptyDirectory = errors.New("directory is empty")
+
+// maxDefaultExtractWorkers caps the number of files extracted at once when Client.ExtractWorkers isn't set
+const maxDefaultExtractWorkers = 8
+
+// TempSuffix is appended to the name of files while they are written
+const TempSuffix = ".seafile-client.tmp"
+
+const (
+	defaultFileMode = os.FileMode(0644)
+	defaultDirMode  = os.FileMode(0755)
+)
+
+// Stats describes how much was transferred for a library
+type Stats struct {
+	Files int
+	Bytes int64
+	// Deleted is the number of local entries SyncLibrary removed because they don't exist in the library
+	Deleted int
+}
+
+// RequestDownloadLink returns a link from which dirPath within the library can be downloaded as zip;
+// "/" downloads the whole library. Servers that prepare the zip asynchronously answer with a zip task
+// instead of a link; its progress is polled until the zip is ready.
+func (c *Client) RequestDownloadLink(ctx context.Context, libraryID, dirPath string) (string, error) {
+	query := url.Values{}
+	query.Set("p", dirPath)
+
+	bodyBinary, err := c.getBody(ctx, pathLibraries+libraryID+pathDir+"download/?"+query.Encode())
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		// The reason isn't machine-readable, so whether the directory is empty is looked up
+		if entries, listErr := c.ListDirectory(ctx, libraryID, dirPath); listErr == nil && len(entries) == 0 {
+			return "", fmt.Errorf("%w: %s", ErrEmptyDirectory, dirPath)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var task zipTask
+	if json.Unmarshal(bodyBinary, &task) == nil && len(task.Token) > 0 {
+		return c.waitForZip(ctx, task.Token, libraryID)
+	}
+
+	return c.fileServerLink(strings.Trim(string(bodyBinary), "\"")), nil
+}
+
+// DownloadLibrary downloads the zip behind downloadLink and extracts it into libraryDir, or stores it as
+// libraryDir.zip or libraryDir.tar.gz depending on c.OutputFormat. The archive is buffered in c.TempDir
+// rather than in memory; an interrupted download is resumed by the next call for the same library if the
+// server supports range requests.
+func (c *Client) DownloadLibrary(ctx context.Context, library Library, downloadLink, libraryDir string) (Stats, error) {
+	return c.downloadZip(ctx, downloadLink, "seafile-"+library.Id, library.Name, libraryDir, libraryDir)
+}
+
+// DownloadDirectory downloads dirPath within the library as zip and extracts it into outputDir; the
+// directory itself becomes the top directory of the extracted tree
+func (c *Client) DownloadDirectory(ctx context.Context, libraryID, dirPath, outputDir string) (Stats, error) {
+	downloadLink, err := c.RequestDownloadLink(ctx, libraryID, dirPath)
+	if err != nil {
+		return Stats{}, fmt.Errorf("unable to request download link: %w", err)
+	}
+
+	// Downloads of different directories must not resume each other
+	sum := sha1.Sum([]byte(dirPath))
+	partName := fmt.Sprintf("seafile-%s-%x", libraryID, sum[:4])
+
+	archiveName := libraryID
+	if dirPath != "/" {
+		archiveName = sanitizeName(path.Base(dirPath))
+	}
+
+	return c.downloadZip(ctx, downloadLink, partName, libraryID+":"+dirPath, filepath.Join(outputDir, archiveName), outputDir)
+}
+
+// downloadZip downloads the zip behind downloadLink and stores it according to c.OutputFormat (see storeZip)
+func (c *Client) downloadZip(ctx context.Context, downloadLink, partName, name, archivePath, extractDir string) (Stats, error) {
+	var (
+		stats   Stats
+		err     error
+		tempDir = c.TempDir
+	)
+
+	if len(tempDir) == 0 {
+		tempDir = os.TempDir()
+	}
+	partPath := filepath.Join(tempDir, partName+".zip.part")
+
+	stats.Bytes, err = c.downloadResumable(ctx, downloadLink, partPath, name)
+	if err != nil {
+		return stats, err
+	}
+	defer removePartial(partPath)
+
+	stats.Files, err = c.storeZip(ctx, partPath, archivePath, extractDir)
+	return stats, err
+}
+
+// extractZip extracts the archive into outputDir and returns the number of files extracted. Entries that
+// fail don't stop the extraction; they are returned as a *PartialError. With c.Manifest, the hashes of the
+// extracted files are written to the manifest in outputDir.
+func (c *Client) extractZip(ctx context.Context, zipPath, outputDir string) (int, error) {
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer zipReader.Close()
+
+	// Also for a zip without entries, so an empty library has its directory like any other
+	if err = os.MkdirAll(outputDir, defaultDirMode); err != nil {
+		return 0, err
+	}
+
+	var (
+		extracted int
+		errs      []error
+		mu        sync.Mutex
+	)
+
+	// Directory mtimes are restored at the very end, as extracting files into them changes their mtime
+	dirTimes := make(map[string]time.Time)
+
+	var sums map[string]string
+	if c.Manifest {
+		sums = make(map[string]string)
+	}
+
+	// All directories are created upfront, so the workers only write files and never race creating them
+	var (
+		jobs  []extractJob
+		index = make(map[string]int)
+	)
+	for _, file := range zipReader.File {
+		if err := ctx.Err(); err != nil {
+			return extracted, err
+		}
+
+		outputPath, err := c.localPath(outputDir, file.Name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if file.FileInfo().IsDir() {
+			// The owner always needs access, otherwise the files inside can't be extracted
+			err = os.MkdirAll(outputPath, entryMode(file, defaultDirMode)|0700)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to create directory %s: %w", file.Name, err))
+				continue
+			}
+			dirTimes[outputPath] = file.Modified
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(outputPath), defaultDirMode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to create directory for %s: %w", file.Name, err))
+			continue
+		}
+
+		// Of entries with the same path, the last one wins, as it did when they were extracted one by one
+		if i, ok := index[outputPath]; ok {
+			jobs[i].file = file
+			continue
+		}
+		index[outputPath] = len(jobs)
+		jobs = append(jobs, extractJob{file: file, outputPath: outputPath})
+	}
+
+	// Extracting many small files is bound by the latency of the filesystem, so several are written at once;
+	// entries can be read from the zip independently
+	queue := make(chan extractJob)
+	var wg sync.WaitGroup
+	for i := 0; i < min(c.extractWorkers(), max(len(jobs), 1)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				var sum hash.Hash
+				if sums != nil {
+					sum = sha256.New()
+				}
+
+				err := extractFile(job.file, job.outputPath, sum)
+				if err == nil {
+					c.setModTime(job.outputPath, job.file.Modified)
+				}
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("unable to extract %s: %w", job.file.Name, err))
+				} else {
+					if sum != nil {
+						if rel, err := filepath.Rel(outputDir, job.outputPath); err == nil {
+							sums[filepath.ToSlash(rel)] = hex.EncodeToString(sum.Sum(nil))
+						}
+					}
+					extracted++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return extracted, err
+	}
+
+	if sums != nil {
+		if err := writeManifest(outputDir, sums); err != nil {
+			errs = append(errs, fmt.Errorf("unable to write %s: %w", ManifestName, err))
+		}
+	}
+
+	for dir, modified := range dirTimes {
+		c.setModTime(dir, modified)
+	}
+	return extracted, newPartialError(errs)
+}
+
+// extractJob is an entry of the zip to extract to outputPath
+type extractJob struct {
+	file       *zip.File
+	outputPath string
+}
+
+func (c *Client) extractWorkers() int {
+	if c.ExtractWorkers < 1 {
+		return min(runtime.NumCPU(), maxDefaultExtractWorkers)
+	}
+
+	return c.ExtractWorkers
+}
+
+func (c *Client) setModTime(path string, modified time.Time) {
+	if modified.IsZero() {
+		return
+	}
+
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		c.logger().Warn("Unable to set modification time", "path", path, "error", err)
+	}
+}
+
+// safeJoin joins name onto base, refusing names that would end up outside of base (zip-slip)
+func safeJoin(base, name string) (string, error) {
+	joined := filepath.Join(base, name)
+	rel, err := filepath.Rel(base, joined)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("path %q escapes output directory %q", name, base)
+	}
+
+	return joined, nil
+}
+
+// extractFile writes the entry to outputPath, and to sum if it isn't nil
+func extractFile(file *zip.File, outputPath string, sum io.Writer) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return writeStream(outputPath, rc, entryMode(file, defaultFileMode), sum)
+}
+
+// entryMode returns the permission bits stored in the zip entry, or fallback if it doesn't carry any
+func entryMode(file *zip.File, fallback os.FileMode) os.FileMode {
+	if perm := file.Mode().Perm(); perm != 0 {
+		return perm
+	}
+
+	return fallback
+}
+
+// writeStream writes everything from r to a new file at outputPath, and to also if it isn't nil. The file is
+// written next to outputPath with TempSuffix first and only renamed into place once complete, so an interrupted
+// write never leaves a truncated file under the final name. The mode is subject to the umask, like any other
+// newly created file.
+func writeStream(outputPath string, r io.Reader, mode os.FileMode, also io.Writer) error {
+	tmpPath := outputPath + TempSuffix
+	err := os.Remove(tmpPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	out, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = out
+	if also != nil {
+		w = io.MultiWriter(out, also)
+	}
+
+	_, err = io.Copy(w, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err = os.Rename(tmpPath, outputPath); err != nil {
+		// Windows refuses to replace read-only files
+		if removeErr := os.Remove(outputPath); removeErr == nil {
+			err = os.Rename(tmpPath, outputPath)
+		}
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// RemoveTempFiles removes the files with TempSuffix that interrupted writes left behind below dir, and returns
+// how many it removed. It must not run while another process may be writing into dir.
+func RemoveTempFiles(dir string) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.HasSuffix(d.Name(), TempSuffix) {
+			return nil
+		}
+
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+
+	return removed, err
+}
+
+// downloadToFile streams the contents of downloadLink to outputPath
+func (c *Client) downloadToFile(ctx context.Context, downloadLink, outputPath string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadLink, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doTransfer(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return err
+	}
+
+	return writeStream(outputPath, c.throttle(ctx, resp.Body), defaultFileMode, nil)
+}
@@ -0,0 +1,274 @@
+package seafile
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zip"
+)
+
+// zipEntry is an entry of a zip built by writeTestZip; names ending in a slash are directories
+type zipEntry struct {
+	name     string
+	body     string
+	mode     os.FileMode
+	modified time.Time
+}
+
+// writeTestZip writes a zip with the entries into a temporary directory and returns its path
+func writeTestZip(t *testing.T, entries ...zipEntry) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	w := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: entry.modified}
+		if entry.mode != 0 {
+			header.SetMode(entry.mode)
+		}
+
+		entryWriter, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = entryWriter.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractZipRejectsEscapingPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		escapes bool
+	}{
+		{"parent", "../evil.txt", true},
+		{"nested parent", "docs/../../evil.txt", true},
+		{"deep parent", "a/b/../../../evil.txt", true},
+		{"inside", "docs/../good.txt", false},
+		{"absolute", "/good.txt", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			zipPath := writeTestZip(t, zipEntry{name: test.entry, body: "content"}, zipEntry{name: "other.txt", body: "other"})
+			root := t.TempDir()
+			outputDir := filepath.Join(root, "out")
+
+			files, err := NewClient("").extractZip(context.Background(), zipPath, outputDir)
+
+			var partial *PartialError
+			if test.escapes {
+				if !errors.As(err, &partial) || partial.Failed != 1 {
+					t.Fatalf("expected a PartialError for 1 entry, got %v", err)
+				}
+				if files != 1 {
+					t.Errorf("expected the other entry to be extracted, got %d files", files)
+				}
+				if _, err := os.Stat(filepath.Join(root, "evil.txt")); !os.IsNotExist(err) {
+					t.Errorf("entry was written outside the output directory")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(outputDir, "good.txt")); err != nil {
+				t.Errorf("entry wasn't extracted into the output directory: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractZipKeepsModificationTimes(t *testing.T) {
+	fileTime := time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+	dirTime := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	zipPath := writeTestZip(t,
+		zipEntry{name: "docs/", modified: dirTime},
+		zipEntry{name: "docs/report.txt", body: "report", modified: fileTime},
+	)
+	outputDir := t.TempDir()
+
+	if _, err := NewClient("").extractZip(context.Background(), zipPath, outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want time.Time
+	}{
+		{"docs/report.txt", fileTime},
+		// Extracting the file into the directory changes its mtime, so this checks it is restored afterwards
+		{"docs", dirTime},
+	}
+	for _, test := range tests {
+		info, err := os.Stat(filepath.Join(outputDir, test.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(test.want) {
+			t.Errorf("%s has mtime %v, want %v", test.path, info.ModTime().UTC(), test.want)
+		}
+	}
+}
+
+// failingReader returns an error once the content is read, like a connection that breaks off
+type failingReader struct {
+	r io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestWriteStreamInterrupted(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+	}{
+		{"new file", ""},
+		{"replacing a file", "complete old content"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "report.txt")
+			if len(test.existing) > 0 {
+				if err := os.WriteFile(outputPath, []byte(test.existing), defaultFileMode); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := writeStream(outputPath, &failingReader{strings.NewReader("the first half of the")}, defaultFileMode, nil)
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("got error %v, want the read error", err)
+			}
+
+			data, err := os.ReadFile(outputPath)
+			if len(test.existing) == 0 && !os.IsNotExist(err) {
+				t.Errorf("the interrupted write left %q under the final name", data)
+			} else if len(test.existing) > 0 && string(data) != test.existing {
+				t.Errorf("the interrupted write replaced the file with %q", data)
+			}
+			if _, err := os.Stat(outputPath + TempSuffix); !os.IsNotExist(err) {
+				t.Errorf("the temporary file wasn't removed")
+			}
+		})
+	}
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	// A process that is killed while writing leaves its temporary files behind
+	files := map[string]bool{
+		"report.txt":                    false,
+		"report.txt" + TempSuffix:       true,
+		"docs/notes.txt" + TempSuffix:   true,
+		"docs/notes.txt":                false,
+		"docs/archive.tmp":              false,
+		"docs/deeper/data" + TempSuffix: true,
+	}
+	for name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), defaultDirMode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), defaultFileMode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := RemoveTempFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d files, want 3", removed)
+	}
+
+	for name, temporary := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists == temporary {
+			t.Errorf("%s exists: %v, want %v", name, exists, !temporary)
+		}
+	}
+
+	if removed, err = RemoveTempFiles(filepath.Join(dir, "missing")); err != nil || removed != 0 {
+		t.Errorf("a missing directory gave %d and %v, want nothing to remove", removed, err)
+	}
+}
+
+func TestExtractEmptyZip(t *testing.T) {
+	zipPath := writeTestZip(t)
+	outputDir := filepath.Join(t.TempDir(), "library")
+
+	files, err := NewClient("").extractZip(context.Background(), zipPath, outputDir)
+	if err != nil || files != 0 {
+		t.Fatalf("got %d files and error %v, want an empty extraction", files, err)
+	}
+	if info, err := os.Stat(outputDir); err != nil || !info.IsDir() {
+		t.Errorf("the directory of the empty library wasn't created: %v", err)
+	}
+}
+
+func TestRequestDownloadLinkOfEmptyDirectory(t *testing.T) {
+	tests := []struct {
+		name    string
+		listing string
+		empty   bool
+	}{
+		{"empty", "[]", true},
+		{"not empty", `[{"type": "file", "name": "report.txt"}]`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api2/repos/lib-1/dir/download/":
+					http.Error(w, `{"error_msg": "Unable to download directory"}`, http.StatusBadRequest)
+				case "/api2/repos/lib-1/dir/":
+					w.Write([]byte(test.listing))
+				default:
+					t.Errorf("unexpected request %s", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			_, err := NewClient(server.URL+"/api2").RequestDownloadLink(context.Background(), "lib-1", "/")
+			if errors.Is(err, ErrEmptyDirectory) != test.empty {
+				t.Errorf("got error %v, want ErrEmptyDirectory: %v", err, test.empty)
+			}
+
+			var apiErr *APIError
+			if !test.empty && (!errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest) {
+				t.Errorf("expected the error of the server, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package seafile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestExtractZipFileModes(t *testing.T) {
+	// Extracted files are subject to the umask like any other new file
+	defer syscall.Umask(syscall.Umask(022))
+
+	zipPath := writeTestZip(t,
+		zipEntry{name: "readonly.txt", body: "read me", mode: 0444},
+		zipEntry{name: "script.sh", body: "#!/bin/sh", mode: 0755},
+		zipEntry{name: "shared.txt", body: "shared", mode: 0664},
+		zipEntry{name: "private/", mode: os.ModeDir | 0700},
+		zipEntry{name: "private/notes.txt", body: "notes", mode: 0600},
+	)
+	outputDir := t.TempDir()
+
+	if _, err := NewClient("").extractZip(context.Background(), zipPath, outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want os.FileMode
+	}{
+		{"readonly.txt", 0444},
+		{"script.sh", 0755},
+		{"shared.txt", 0644},
+		{"private", 0700},
+		{"private/notes.txt", 0600},
+	}
+	for _, test := range tests {
+		info, err := os.Stat(filepath.Join(outputDir, test.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != test.want {
+			t.Errorf("%s has mode %v, want %v", test.path, info.Mode().Perm(), test.want)
+		}
+	}
+}
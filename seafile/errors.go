@@ -0,0 +1,99 @@
+package seafile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody limits how much of an error response is kept in an APIError
+const maxErrorBody = 64 * 1024
+
+// APIError is returned when the server answers with an unexpected status code
+type APIError struct {
+	StatusCode int
+	Endpoint   string
+	Body       string
+	// Message is the error_msg, detail or non_field_errors Seafile puts in its JSON error bodies, if any
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Message) > 0 {
+		return fmt.Sprintf("%s: server responded with %d: %s", e.Endpoint, e.StatusCode, e.Message)
+	}
+
+	return fmt.Sprintf("%s: server responded with %d", e.Endpoint, e.StatusCode)
+}
+
+// PartialError is returned when some entries of a library could not be written while the others were
+type PartialError struct {
+	Failed int
+	// Err joins the errors of the individual entries
+	Err error
+}
+
+func newPartialError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &PartialError{Failed: len(errs), Err: errors.Join(errs...)}
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d entries failed: %v", e.Failed, e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError builds an APIError from a response of which the body has already been read
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		apiErr.Endpoint = resp.Request.Method + " " + resp.Request.URL.Path
+	}
+
+	var decoded struct {
+		ErrorMsg       string   `json:"error_msg"`
+		Detail         string   `json:"detail"`
+		NonFieldErrors []string `json:"non_field_errors"`
+	}
+	if json.Unmarshal(body, &decoded) == nil {
+		apiErr.Message = decoded.ErrorMsg
+		if len(apiErr.Message) == 0 {
+			apiErr.Message = decoded.Detail
+		}
+		if len(apiErr.Message) == 0 {
+			apiErr.Message = strings.Join(decoded.NonFieldErrors, "; ")
+		}
+	} else if !strings.HasPrefix(strings.TrimSpace(string(body)), "<") {
+		// Plain text bodies are used as-is, HTML error pages would only be noise
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	return apiErr
+}
+
+// checkStatus returns an APIError, including the (unread) body, unless the response has one of the
+// expected status codes
+func checkStatus(resp *http.Response, expected ...int) error {
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return newAPIError(resp, body)
+}
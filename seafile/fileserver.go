@@ -0,0 +1,39 @@
+package seafile
+
+import (
+	"net/url"
+	"strings"
+)
+
+// fileServerEndpoints are the paths of the file server (seafhttp) below its root, as used in the links the
+// API hands out
+var fileServerEndpoints = []string{"/files/", "/zip/", "/upload-api/", "/update-api/", "/upload-aj/"}
+
+// fileServerLink points a download or upload link at c.FileServerURL, if set. The root the server builds
+// its links from isn't known, so the link is cut at the first file server endpoint in its path.
+func (c *Client) fileServerLink(link string) string {
+	if len(c.FileServerURL) == 0 {
+		return link
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	escaped := u.EscapedPath()
+	for _, endpoint := range fileServerEndpoints {
+		if i := strings.Index(escaped, endpoint); i >= 0 {
+			rewritten := strings.TrimSuffix(c.FileServerURL, "/") + escaped[i:]
+			if len(u.RawQuery) > 0 {
+				rewritten += "?" + u.RawQuery
+			}
+
+			c.logger().Debug("Using the configured file server", "link", u.Redacted(), "file_server", c.FileServerURL)
+			return rewritten
+		}
+	}
+
+	c.logger().Debug("Not a file server link, leaving it as is", "link", u.Redacted())
+	return link
+}
@@ -0,0 +1,64 @@
+package seafile
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// The ID Seafile gives a file is the SHA-1 of its file object, which lists the SHA-1 IDs of its blocks. In
+// libraries of version 0, the object is the concatenated (binary) block IDs; since version 1 it is JSON with
+// sorted keys: {"block_ids": ["<hex>", ...], "size": <bytes>, "type": 1, "version": 1}. How a file is split
+// into blocks depends on the uploading client (content defined chunking, or fixed size blocks on the
+// server), which can't be reproduced here. Files smaller than the minimum block size are always stored as a
+// single block though, so for those the ID can be computed locally.
+const (
+	reproducibleIdMaxSize = 256 * 1024
+	emptyFileId           = "0000000000000000000000000000000000000000"
+)
+
+// localFileId computes the ID Seafile gives the local file in a library of repoVersion. It returns false when
+// the ID isn't reproducible, because the file is too large or the version unknown, in which case the caller
+// should fall back to comparing size and mtime.
+func localFileId(localPath string, repoVersion int) (string, bool, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	if info.Size() == 0 {
+		return emptyFileId, true, nil
+	}
+	if info.Size() >= reproducibleIdMaxSize || repoVersion < 0 || repoVersion > 1 {
+		return "", false, nil
+	}
+
+	data, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	blockId := sha1.Sum(data)
+	object := blockId[:]
+	if repoVersion == 1 {
+		// The separators are those of jansson, which the server serializes the object with
+		object = []byte(fmt.Sprintf(`{"block_ids": ["%x"], "size": %d, "type": 1, "version": 1}`, blockId, len(data)))
+	}
+
+	fileId := sha1.Sum(object)
+	return hex.EncodeToString(fileId[:]), true, nil
+}
+
+// contentMatches reports whether the local file is known to have the same content as the remote entry of a
+// library of repoVersion
+func contentMatches(localPath string, entry DirEntry, repoVersion int) bool {
+	if len(entry.Id) == 0 {
+		return false
+	}
+
+	id, ok, err := localFileId(localPath, repoVersion)
+	return err == nil && ok && strings.EqualFold(id, entry.Id)
+}
@@ -0,0 +1,41 @@
+package seafile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalFileId(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		repoVersion int
+		wantId      string
+		reproduce   bool
+	}{
+		{"version 0", "hello\n", 0, "8ff75d397c35eab0cf4b882bc703f4b0eb049c62", true},
+		{"version 1", "hello\n", 1, "4226e5b3d7da1a4575241f9501f9882aee9b5ea9", true},
+		{"empty", "", 1, emptyFileId, true},
+		{"unknown version", "hello\n", 2, "", false},
+		{"several blocks", strings.Repeat("x", reproducibleIdMaxSize), 1, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(path, []byte(test.content), defaultFileMode); err != nil {
+				t.Fatal(err)
+			}
+
+			id, ok, err := localFileId(path, test.repoVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != test.wantId || ok != test.reproduce {
+				t.Errorf("got ID %q, reproducible: %v; want %q, %v", id, ok, test.wantId, test.reproduce)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const pathFileHistory = "/file/history/"
+
+// Commit is a revision of a file as listed in its history
+type Commit struct {
+	Id          string `json:"id"`
+	Description string `json:"desc"`
+	Creator     string `json:"creator_name"`
+	Ctime       int64  `json:"ctime"`
+	// FileId and Size describe the file as of this commit
+	FileId string `json:"rev_file_id"`
+	Size   int64  `json:"rev_file_size"`
+}
+
+// Time returns when the commit was made
+func (c Commit) Time() time.Time {
+	return time.Unix(c.Ctime, 0)
+}
+
+// ListFileHistory returns the revisions of the file at filePath, newest first
+func (c *Client) ListFileHistory(ctx context.Context, libraryID, filePath string) ([]Commit, error) {
+	query := url.Values{}
+	query.Set("p", filePath)
+
+	req, err := c.newRequest(ctx, "GET", pathLibraries+libraryID+pathFileHistory+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, bodyBinary)
+	}
+
+	var history struct {
+		Commits []Commit `json:"commits"`
+	}
+	err = json.Unmarshal(bodyBinary, &history)
+	if err != nil {
+		return nil, err
+	}
+
+	return history.Commits, nil
+}
+
+// DownloadFileVersion downloads the file at remotePath as it was in the given commit to localPath
+func (c *Client) DownloadFileVersion(ctx context.Context, libraryID, remotePath, commitID, localPath string) error {
+	query := url.Values{}
+	query.Set("p", remotePath)
+	query.Set("commit_id", commitID)
+
+	link, err := c.getLink(ctx, pathLibraries+libraryID+pathFile+"?"+query.Encode())
+	if err != nil {
+		return fmt.Errorf("unable to request download link: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(localPath), defaultDirMode)
+	if err != nil {
+		return err
+	}
+
+	return c.downloadToFile(ctx, link, localPath)
+}
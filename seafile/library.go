@@ -0,0 +1,350 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const librariesPerPage = 100
+
+var (
+	// ErrNotOwner is returned when an operation is only allowed for the owner of a library
+	ErrNotOwner = errors.New("not the owner of the library")
+	// ErrLibraryNotFound is returned when a library doesn't exist
+	ErrLibraryNotFound = errors.New("library does not exist")
+	// ErrLibraryNameRejected is returned when the server refuses a library name, e.g. because another library
+	// already has it
+	ErrLibraryNameRejected = errors.New("library name rejected")
+	// ErrReadOnly is returned when trying to change a library the user may only read
+	ErrReadOnly = errors.New("library is read-only")
+)
+
+// LibraryType selects which libraries ListLibraries returns
+type LibraryType string
+
+const (
+	LibrariesMine   LibraryType = "mine"
+	LibrariesShared LibraryType = "shared"
+	LibrariesGroup  LibraryType = "group"
+	// LibrariesPublic are the libraries shared with all users of the server, which api2 calls "org"
+	LibrariesPublic LibraryType = "org"
+)
+
+// Library is a Seafile library (repository) as listed by the server
+type Library struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Encrypted bool   `json:"encrypted"`
+	// Size is the total size of the files in the library, in bytes
+	Size int64 `json:"size"`
+	// Type is "repo" for own libraries, "srepo" for libraries shared with the user and "grepo" for group libraries
+	Type string `json:"type"`
+	// Owner is the email of the owner of the library, who shared it for shared libraries
+	Owner string `json:"owner"`
+	// Permission is "rw" for libraries the user may change and "r" for read-only ones
+	Permission string `json:"permission"`
+	// FileCount is the number of files in the library; only some servers report it, and only in its details
+	FileCount int `json:"file_count"`
+	// Version is the format the library stores its objects in; it determines how file IDs are computed
+	Version int `json:"version"`
+	// HeadCommitId changes with every change to the library
+	HeadCommitId string `json:"head_commit_id"`
+	// Mtime is sent by api2 as a Unix timestamp, LastModified by newer servers as an ISO 8601 date
+	Mtime        int64  `json:"mtime"`
+	LastModified string `json:"last_modified"`
+}
+
+// ModTime returns when the library was last modified, or the zero time if the server didn't say
+func (l Library) ModTime() time.Time {
+	if modified, err := time.Parse(time.RFC3339, l.LastModified); err == nil {
+		return modified
+	}
+	if l.Mtime > 0 {
+		return time.Unix(l.Mtime, 0)
+	}
+
+	return time.Time{}
+}
+
+// ReadOnly reports whether the user may only read the library
+func (l Library) ReadOnly() bool {
+	return l.Permission == "r"
+}
+
+// ListLibraries collects the libraries of the given types, or those the server lists by default when no
+// type is given. A library that is listed for several types (e.g. shared with the user and with one of their
+// groups) is only returned once.
+func (c *Client) ListLibraries(ctx context.Context, types ...LibraryType) ([]Library, error) {
+	if len(types) == 0 {
+		types = []LibraryType{""}
+	}
+
+	var libraries []Library
+	seen := make(map[string]bool)
+	for _, libraryType := range types {
+		var err error
+		libraries, err = c.listLibrariesOfType(ctx, libraryType, libraries, seen)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list %s libraries: %w", libraryType, err)
+		}
+	}
+
+	return libraries, nil
+}
+
+// listLibrariesOfType appends the libraries of one type, from all pages, that aren't in seen yet. Servers
+// that don't paginate ignore the page parameters and return everything at once, so a page that adds no new
+// libraries ends the loop.
+func (c *Client) listLibrariesOfType(ctx context.Context, libraryType LibraryType, libraries []Library, seen map[string]bool) ([]Library, error) {
+	for page := 1; ; page++ {
+		pageLibraries, err := c.listLibrariesPage(ctx, libraryType, page)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, library := range pageLibraries {
+			if !seen[library.Id] {
+				seen[library.Id] = true
+				libraries = append(libraries, library)
+				added++
+			}
+		}
+
+		if len(pageLibraries) < librariesPerPage || added == 0 {
+			return libraries, nil
+		}
+	}
+}
+
+func (c *Client) listLibrariesPage(ctx context.Context, libraryType LibraryType, page int) ([]Library, error) {
+	query := url.Values{}
+	if len(libraryType) > 0 {
+		query.Set("type", string(libraryType))
+	}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(librariesPerPage))
+
+	req, err := c.newRequest(ctx, "GET", pathLibraries+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, bodyBinary)
+	}
+
+	var libraries []Library
+	err = json.Unmarshal(bodyBinary, &libraries)
+	if err != nil {
+		return nil, err
+	}
+
+	return libraries, nil
+}
+
+// GetLibrary returns the library with the given ID, or ErrLibraryNotFound
+func (c *Client) GetLibrary(ctx context.Context, libraryID string) (Library, error) {
+	req, err := c.newRequest(ctx, "GET", pathLibraries+libraryID+"/", nil)
+	if err != nil {
+		return Library{}, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return Library{}, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Library{}, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Library{}, ErrLibraryNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Library{}, newAPIError(resp, bodyBinary)
+	}
+
+	var library Library
+	err = json.Unmarshal(bodyBinary, &library)
+	if err != nil {
+		return Library{}, err
+	}
+
+	return library, nil
+}
+
+// checkWritable returns ErrReadOnly when the user may not change the library, so uploads fail before
+// sending anything rather than with a 403 afterwards
+func (c *Client) checkWritable(ctx context.Context, libraryID string) error {
+	library, err := c.GetLibrary(ctx, libraryID)
+	if err != nil {
+		return err
+	}
+
+	if library.ReadOnly() {
+		return fmt.Errorf("%s: %w", library.Name, ErrReadOnly)
+	}
+
+	return nil
+}
+
+// CreateLibrary creates a new library; it is encrypted with password when encrypted is set
+func (c *Client) CreateLibrary(ctx context.Context, name string, encrypted bool, password string) (Library, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return Library{}, errors.New("library name must not be empty")
+	}
+	if encrypted && len(password) == 0 {
+		return Library{}, errors.New("an encrypted library needs a password")
+	}
+
+	data := url.Values{}
+	data.Set("name", name)
+	if encrypted {
+		data.Set("passwd", password)
+	}
+
+	req, err := c.newFormRequest(ctx, "POST", pathLibraries, data)
+	if err != nil {
+		return Library{}, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return Library{}, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Library{}, err
+	}
+
+	if resp.StatusCode == http.StatusBadRequest {
+		return Library{}, fmt.Errorf("server rejected library name %q (does a library with that name exist already?): %w", name, newAPIError(resp, bodyBinary))
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return Library{}, newAPIError(resp, bodyBinary)
+	}
+
+	var created struct {
+		Id   string `json:"repo_id"`
+		Name string `json:"repo_name"`
+	}
+	err = json.Unmarshal(bodyBinary, &created)
+	if err != nil {
+		return Library{}, err
+	}
+
+	return Library{Id: created.Id, Name: created.Name}, nil
+}
+
+// DeleteLibrary removes the library. It returns ErrNotOwner or ErrLibraryNotFound when the server
+// refuses with 403 or 404 respectively.
+func (c *Client) DeleteLibrary(ctx context.Context, libraryID string) error {
+	req, err := c.newRequest(ctx, "DELETE", pathLibraries+libraryID+"/", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusForbidden:
+		return ErrNotOwner
+	case http.StatusNotFound:
+		return ErrLibraryNotFound
+	default:
+		return checkStatus(resp, http.StatusOK, http.StatusNoContent)
+	}
+}
+
+// RenameLibrary changes the name of the library. Besides ErrNotOwner and ErrLibraryNotFound, it returns
+// ErrLibraryNameRejected when the server refuses the name with 400.
+func (c *Client) RenameLibrary(ctx context.Context, libraryID, newName string) error {
+	if len(strings.TrimSpace(newName)) == 0 {
+		return errors.New("library name can't be empty")
+	}
+
+	data := url.Values{}
+	data.Set("repo_name", newName)
+
+	req, err := c.newFormRequest(ctx, "POST", pathLibraries+libraryID+"/?op=rename", data)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %v", ErrLibraryNameRejected, checkStatus(resp, http.StatusOK))
+	case http.StatusForbidden:
+		return ErrNotOwner
+	case http.StatusNotFound:
+		return ErrLibraryNotFound
+	default:
+		return checkStatus(resp, http.StatusOK)
+	}
+}
+
+// UnlockLibrary decrypts an encrypted library on the server for the current session, which is needed
+// before its contents can be downloaded
+func (c *Client) UnlockLibrary(ctx context.Context, libraryID, password string) error {
+	data := url.Values{}
+	data.Set("password", password)
+
+	req, err := c.newFormRequest(ctx, "POST", pathLibraries+libraryID+"/", data)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest {
+		return errors.New("wrong password for encrypted library")
+	}
+
+	return checkStatus(resp, http.StatusOK)
+}
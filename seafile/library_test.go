@@ -0,0 +1,130 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// testLibraries returns n libraries with distinct IDs, starting at first
+func testLibraries(first, n int) []Library {
+	libraries := make([]Library, n)
+	for i := range libraries {
+		libraries[i] = Library{Id: fmt.Sprintf("lib-%d", first+i), Name: fmt.Sprintf("Library %d", first+i)}
+	}
+	return libraries
+}
+
+func TestListLibrariesPages(t *testing.T) {
+	tests := []struct {
+		name string
+		// pages are the libraries the server returns per page; pages beyond them are empty
+		pages [][]Library
+		// paginates is false for servers that ignore the page parameters and always return everything
+		paginates bool
+		want      int
+	}{
+		{"single page", [][]Library{testLibraries(0, 3)}, true, 3},
+		{"two pages", [][]Library{testLibraries(0, librariesPerPage), testLibraries(librariesPerPage, 5)}, true, librariesPerPage + 5},
+		{"exactly one full page", [][]Library{testLibraries(0, librariesPerPage)}, true, librariesPerPage},
+		{"no pagination", [][]Library{testLibraries(0, librariesPerPage+5)}, false, librariesPerPage + 5},
+		{"no libraries", nil, true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				if !test.paginates {
+					page = 1
+				}
+
+				libraries := []Library{}
+				if page >= 1 && page <= len(test.pages) {
+					libraries = test.pages[page-1]
+				}
+				json.NewEncoder(w).Encode(libraries)
+			}))
+			defer server.Close()
+
+			libraries, err := NewClient(server.URL + "/api2").ListLibraries(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(libraries) != test.want {
+				t.Errorf("got %d libraries, want %d", len(libraries), test.want)
+			}
+			for i, library := range libraries {
+				if library.Id != fmt.Sprintf("lib-%d", i) {
+					t.Errorf("library %d has ID %s, expected the server order", i, library.Id)
+					break
+				}
+			}
+			if requests > len(test.pages)+1 {
+				t.Errorf("made %d requests for %d pages", requests, len(test.pages))
+			}
+		})
+	}
+}
+
+func TestDeleteLibrary(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+	}{
+		{"deleted", http.StatusOK, nil},
+		{"deleted without content", http.StatusNoContent, nil},
+		{"not the owner", http.StatusForbidden, ErrNotOwner},
+		{"already gone", http.StatusNotFound, ErrLibraryNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "DELETE" || r.URL.Path != "/api2/repos/lib-1/" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				if r.Header.Get("Authorization") != "Token secret" {
+					t.Errorf("request isn't authenticated: %q", r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL + "/api2")
+			client.Token = "secret"
+			err := client.DeleteLibrary(context.Background(), "lib-1")
+			if !errors.Is(err, test.wantErr) || (test.wantErr == nil && err != nil) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+
+	t.Run("server error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error_msg": "Internal Server Error"}`, http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL + "/api2")
+		client.MaxRetries = 0
+		err := client.DeleteLibrary(context.Background(), "lib-1")
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+			t.Errorf("expected an APIError with status 500, got %v", err)
+		}
+		if errors.Is(err, ErrNotOwner) || errors.Is(err, ErrLibraryNotFound) {
+			t.Errorf("a server error must not look like %v", err)
+		}
+	})
+}
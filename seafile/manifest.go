@@ -0,0 +1,120 @@
+package seafile
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestName is the name of the manifest written next to the extracted files of a library when
+// c.Manifest is set. It lists the SHA-256 of every file in the format of sha256sum, so it can also be
+// checked with sha256sum -c.
+const ManifestName = "SHA256SUMS"
+
+// ManifestMismatch is a file that doesn't match its manifest entry
+type ManifestMismatch struct {
+	Path    string
+	Problem string
+}
+
+// writeManifest writes the hashes, by path relative to dir, to the manifest in dir
+func writeManifest(dir string, sums map[string]string) error {
+	paths := make([]string, 0, len(sums))
+	for path := range sums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		name := path
+		// Like sha256sum, names with a backslash or newline are escaped and marked by a leading backslash
+		if strings.ContainsAny(name, "\\\n") {
+			b.WriteString("\\")
+			name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		}
+		fmt.Fprintf(&b, "%s  %s\n", sums[path], name)
+	}
+
+	manifestPath := filepath.Join(dir, ManifestName)
+	tmpPath := manifestPath + TempSuffix
+	if err := ioutil.WriteFile(tmpPath, []byte(b.String()), defaultFileMode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, manifestPath)
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file at path
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifyManifest checks the files in dir against the manifest in dir. It returns the number of files
+// checked and those that are missing or have changed.
+func VerifyManifest(ctx context.Context, dir string) (int, []ManifestMismatch, error) {
+	file, err := os.Open(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	var (
+		checked    int
+		mismatches []ManifestMismatch
+	)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if err = ctx.Err(); err != nil {
+			return checked, mismatches, err
+		}
+
+		entry := scanner.Text()
+		escaped := strings.HasPrefix(entry, "\\")
+		sum, name, ok := strings.Cut(strings.TrimPrefix(entry, "\\"), "  ")
+		if !ok || len(sum) != hex.EncodedLen(sha256.Size) {
+			return checked, mismatches, fmt.Errorf("%s line %d: invalid entry", ManifestName, line)
+		}
+		if escaped {
+			name = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(name)
+		}
+
+		checked++
+		path, err := safeJoin(dir, filepath.FromSlash(name))
+		if err != nil {
+			mismatches = append(mismatches, ManifestMismatch{Path: name, Problem: err.Error()})
+			continue
+		}
+
+		actual, err := hashFile(path)
+		if os.IsNotExist(err) {
+			mismatches = append(mismatches, ManifestMismatch{Path: name, Problem: "missing"})
+		} else if err != nil {
+			mismatches = append(mismatches, ManifestMismatch{Path: name, Problem: err.Error()})
+		} else if !strings.EqualFold(actual, sum) {
+			mismatches = append(mismatches, ManifestMismatch{Path: name, Problem: "checksum mismatch"})
+		}
+	}
+
+	return checked, mismatches, scanner.Err()
+}
@@ -0,0 +1,140 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// pathFileOpsMove moves entries of a directory; unlike the file and dir endpoints, it can move directories
+const pathFileOpsMove = "/fileops/move/"
+
+// MoveResult describes where a moved or renamed entry ended up
+type MoveResult struct {
+	Path string
+	// Renamed is set when the destination already existed and the server picked another name, e.g. "a (1).txt"
+	Renamed bool
+}
+
+// MoveEntry moves the file (or directory, if isDir is set) at srcPath into dstDir of the same library
+func (c *Client) MoveEntry(ctx context.Context, libraryID, srcPath, dstDir string, isDir bool) (MoveResult, error) {
+	if isDir {
+		return c.moveDirectory(ctx, libraryID, srcPath, dstDir)
+	}
+
+	data := url.Values{}
+	data.Set("operation", "move")
+	data.Set("dst_repo", libraryID)
+	data.Set("dst_dir", dstDir)
+
+	return c.entryOperation(ctx, libraryID, srcPath, isDir, data, path.Join(dstDir, path.Base(srcPath)))
+}
+
+// RenameEntry renames the file (or directory, if isDir is set) at srcPath to newName
+func (c *Client) RenameEntry(ctx context.Context, libraryID, srcPath, newName string, isDir bool) (MoveResult, error) {
+	data := url.Values{}
+	data.Set("operation", "rename")
+	data.Set("newname", newName)
+
+	return c.entryOperation(ctx, libraryID, srcPath, isDir, data, path.Join(path.Dir(srcPath), newName))
+}
+
+// entryOperation posts an operation on the entry at srcPath. The server answers with a redirect to the
+// resulting entry, which tells whether it was renamed to avoid a conflict.
+func (c *Client) entryOperation(ctx context.Context, libraryID, srcPath string, isDir bool, data url.Values, wanted string) (MoveResult, error) {
+	endpoint := pathFile
+	if isDir {
+		endpoint = pathDir
+	}
+
+	query := url.Values{}
+	query.Set("p", srcPath)
+
+	req, err := c.newFormRequest(ctx, "POST", pathLibraries+libraryID+endpoint+"?"+query.Encode(), data)
+	if err != nil {
+		return MoveResult{}, err
+	}
+
+	// Following the redirect would only request the entry again
+	httpClient := *c.HTTPClient
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := c.retry(&httpClient, req)
+	if err != nil {
+		return MoveResult{}, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return MoveResult{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMovedPermanently {
+		return MoveResult{}, newAPIError(resp, bodyBinary)
+	}
+
+	result := MoveResult{Path: wanted}
+	if location, err := resp.Location(); err == nil {
+		if actual := location.Query().Get("p"); len(actual) > 0 {
+			result.Path = path.Clean(actual)
+		}
+	} else if !errors.Is(err, http.ErrNoLocation) {
+		return MoveResult{}, err
+	}
+
+	result.Renamed = result.Path != path.Clean(wanted)
+	return result, nil
+}
+
+// moveDirectory moves the directory at srcPath into dstDir. The server answers with the name it ended up with,
+// which differs when dstDir already had an entry by that name.
+func (c *Client) moveDirectory(ctx context.Context, libraryID, srcPath, dstDir string) (MoveResult, error) {
+	query := url.Values{}
+	query.Set("p", path.Dir(srcPath))
+
+	data := url.Values{}
+	data.Set("dst_repo", libraryID)
+	data.Set("dst_dir", dstDir)
+	data.Set("file_names", path.Base(srcPath))
+
+	req, err := c.newFormRequest(ctx, "POST", pathLibraries+libraryID+pathFileOpsMove+"?"+query.Encode(), data)
+	if err != nil {
+		return MoveResult{}, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return MoveResult{}, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return MoveResult{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return MoveResult{}, newAPIError(resp, bodyBinary)
+	}
+
+	wanted := path.Join(dstDir, path.Base(srcPath))
+	result := MoveResult{Path: wanted}
+
+	// Servers that don't report the name answer with {"success": true}, so the wanted name is assumed then
+	var moved []struct {
+		Name string `json:"obj_name"`
+	}
+	if json.Unmarshal(bodyBinary, &moved) == nil && len(moved) == 1 && len(moved[0].Name) > 0 {
+		result.Path = path.Join(dstDir, moved[0].Name)
+	}
+
+	result.Renamed = result.Path != wanted
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package seafile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestMoveAndRenameEntry(t *testing.T) {
+	tests := []struct {
+		name string
+		// do performs the operation on the entry
+		do       func(client *Client) (MoveResult, error)
+		wantPath string
+		// wantRequest is the path and form of the request the server should get
+		wantRequest string
+		wantForm    url.Values
+		// answer is the name the server gives the entry; empty means the wanted name
+		answer      string
+		wantRenamed bool
+	}{
+		{
+			name: "move file",
+			do: func(client *Client) (MoveResult, error) {
+				return client.MoveEntry(context.Background(), "lib-1", "/docs/a.txt", "/archive", false)
+			},
+			wantPath:    "/archive/a.txt",
+			wantRequest: "/api2/repos/lib-1/file/?p=/docs/a.txt",
+			wantForm:    url.Values{"operation": {"move"}, "dst_repo": {"lib-1"}, "dst_dir": {"/archive"}},
+		},
+		{
+			name: "move file to a taken name",
+			do: func(client *Client) (MoveResult, error) {
+				return client.MoveEntry(context.Background(), "lib-1", "/docs/a.txt", "/archive", false)
+			},
+			wantPath:    "/archive/a (1).txt",
+			wantRequest: "/api2/repos/lib-1/file/?p=/docs/a.txt",
+			wantForm:    url.Values{"operation": {"move"}, "dst_repo": {"lib-1"}, "dst_dir": {"/archive"}},
+			answer:      "a (1).txt",
+			wantRenamed: true,
+		},
+		{
+			name: "move directory",
+			do: func(client *Client) (MoveResult, error) {
+				return client.MoveEntry(context.Background(), "lib-1", "/docs/2025", "/archive", true)
+			},
+			wantPath:    "/archive/2025",
+			wantRequest: "/api2/repos/lib-1/fileops/move/?p=/docs",
+			wantForm:    url.Values{"file_names": {"2025"}, "dst_repo": {"lib-1"}, "dst_dir": {"/archive"}},
+		},
+		{
+			name: "move directory to a taken name",
+			do: func(client *Client) (MoveResult, error) {
+				return client.MoveEntry(context.Background(), "lib-1", "/docs/2025", "/archive", true)
+			},
+			wantPath:    "/archive/2025 (1)",
+			wantRequest: "/api2/repos/lib-1/fileops/move/?p=/docs",
+			wantForm:    url.Values{"file_names": {"2025"}, "dst_repo": {"lib-1"}, "dst_dir": {"/archive"}},
+			answer:      "2025 (1)",
+			wantRenamed: true,
+		},
+		{
+			name: "rename file",
+			do: func(client *Client) (MoveResult, error) {
+				return client.RenameEntry(context.Background(), "lib-1", "/docs/a.txt", "b.txt", false)
+			},
+			wantPath:    "/docs/b.txt",
+			wantRequest: "/api2/repos/lib-1/file/?p=/docs/a.txt",
+			wantForm:    url.Values{"operation": {"rename"}, "newname": {"b.txt"}},
+		},
+		{
+			name: "rename directory to a taken name",
+			do: func(client *Client) (MoveResult, error) {
+				return client.RenameEntry(context.Background(), "lib-1", "/docs/2025", "old", true)
+			},
+			wantPath:    "/docs/old (1)",
+			wantRequest: "/api2/repos/lib-1/dir/?p=/docs/2025",
+			wantForm:    url.Values{"operation": {"rename"}, "newname": {"old"}},
+			answer:      "old (1)",
+			wantRenamed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("unexpected %s %s", r.Method, r.URL.RequestURI())
+					http.NotFound(w, r)
+					return
+				}
+				if got := r.URL.Path + "?p=" + r.URL.Query().Get("p"); got != test.wantRequest {
+					t.Errorf("request to %s, want %s", got, test.wantRequest)
+				}
+				if err := r.ParseForm(); err != nil {
+					t.Error(err)
+				}
+				for key, want := range test.wantForm {
+					if got := r.PostForm.Get(key); got != want[0] {
+						t.Errorf("form field %s is %q, want %q", key, got, want[0])
+					}
+				}
+
+				if r.URL.Path == "/api2/repos/lib-1/fileops/move/" {
+					if len(test.answer) == 0 {
+						w.Write([]byte(`{"success": true}`))
+						return
+					}
+					w.Write([]byte(`[{"repo_id": "lib-1", "parent_dir": "/archive", "obj_name": "` + test.answer + `"}]`))
+					return
+				}
+
+				// The file and dir endpoints redirect to the resulting entry
+				query := url.Values{}
+				query.Set("p", test.wantPath)
+				w.Header().Set("Location", r.URL.Path+"?"+query.Encode())
+				w.WriteHeader(http.StatusMovedPermanently)
+			}))
+			defer server.Close()
+
+			result, err := test.do(NewClient(server.URL + "/api2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Path != test.wantPath || result.Renamed != test.wantRenamed {
+				t.Errorf("got %+v, want path %s and renamed %v", result, test.wantPath, test.wantRenamed)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package seafile
+
+import (
+	"runtime"
+	"strings"
+)
+
+// reservedNames can't be used as file names on Windows, with or without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeName turns a library name into a name that is safe to use as a single file or directory name
+// on all platforms: path separators, characters Windows reserves and control characters are replaced.
+func sanitizeName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Windows drops trailing dots and spaces, and "." and ".." aren't names at all
+	sanitized = strings.TrimRight(strings.TrimSpace(sanitized), ".")
+	if len(sanitized) == 0 {
+		return "_"
+	}
+
+	base := sanitized
+	if i := strings.Index(base, "."); i >= 0 {
+		base = base[:i]
+	}
+	if reservedNames[strings.ToUpper(base)] {
+		sanitized = "_" + sanitized
+	}
+
+	return sanitized
+}
+
+// LibraryDirNames returns a file name for every library, by ID, that is safe to use as the directory (or
+// archive) the library is stored in. Libraries that share a name get their short ID appended, so they
+// don't overwrite each other.
+func LibraryDirNames(libraries []Library) map[string]string {
+	count := make(map[string]int)
+	for _, library := range libraries {
+		count[strings.ToLower(sanitizeName(library.Name))]++
+	}
+
+	names := make(map[string]string, len(libraries))
+	for _, library := range libraries {
+		name := sanitizeName(library.Name)
+		if count[strings.ToLower(name)] > 1 {
+			name += "-" + shortID(library.Id)
+		}
+		names[library.Id] = name
+	}
+
+	return names
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// windowsPath rewrites every element of a slash separated path to a name Windows accepts
+func windowsPath(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		if part != "" && part != "." && part != ".." {
+			parts[i] = sanitizeName(part)
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// localPath joins the slash separated name from the server onto base like safeJoin. On Windows, names
+// it doesn't allow are rewritten first; the rename is logged, so the files can be mapped back.
+func (c *Client) localPath(base, name string) (string, error) {
+	if runtime.GOOS == "windows" {
+		if safe := windowsPath(name); safe != name {
+			c.logger().Info("Renamed path that is not allowed on Windows", "path", name, "renamed", safe)
+			name = safe
+		}
+	}
+
+	return safeJoin(base, name)
+}
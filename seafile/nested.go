@@ -0,0 +1,179 @@
+package seafile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zip"
+)
+
+const (
+	// DefaultNestedMaxDepth is how many levels of zip files within zip files are extracted by default
+	DefaultNestedMaxDepth = 3
+	// DefaultNestedMaxSize is how much all nested zip files of a library may expand to by default
+	DefaultNestedMaxSize = int64(4 << 30)
+)
+
+// ErrNestedTooLarge is returned when nested zip files expand to more than Client.NestedMaxSize
+var ErrNestedTooLarge = errors.New("nested archives too large")
+
+// extractNested extracts every zip file below dir into a directory next to it, named after the zip without
+// its extension, and the zip files found in there in turn, down to c.NestedMaxDepth levels. Archives whose
+// directory exists already are left alone, so nothing of the library is overwritten. It returns the
+// number of files extracted. Once the nested archives expanded to c.NestedMaxSize bytes, extraction stops
+// with ErrNestedTooLarge. Archives that fail are returned as a *PartialError.
+func (c *Client) extractNested(ctx context.Context, dir string) (int, error) {
+	maxDepth, budget := c.NestedMaxDepth, c.NestedMaxSize
+	if maxDepth <= 0 {
+		maxDepth = DefaultNestedMaxDepth
+	}
+	if budget <= 0 {
+		budget = DefaultNestedMaxSize
+	}
+
+	var (
+		extracted int
+		errs      []error
+	)
+
+	dirs := []string{dir}
+	for depth := 1; len(dirs) > 0; depth++ {
+		var next []string
+		for _, searchDir := range dirs {
+			archives, err := findZips(searchDir)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			for _, archive := range archives {
+				if err := ctx.Err(); err != nil {
+					return extracted, err
+				}
+
+				if depth > maxDepth {
+					c.logger().Warn("Not extracting archive nested too deeply", "path", archive, "max_depth", maxDepth)
+					continue
+				}
+
+				target := strings.TrimSuffix(archive, filepath.Ext(archive))
+				if _, err := os.Lstat(target); err == nil {
+					c.logger().Warn("Not extracting archive, its directory exists already", "path", archive, "directory", target)
+					continue
+				} else if !os.IsNotExist(err) {
+					errs = append(errs, fmt.Errorf("unable to extract nested %s: %w", archive, err))
+					continue
+				}
+
+				files, err := c.extractBounded(ctx, archive, target, &budget)
+				extracted += files
+				if errors.Is(err, ErrNestedTooLarge) {
+					errs = append(errs, fmt.Errorf("%s: %w", archive, err))
+					return extracted, newPartialError(errs)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("unable to extract nested %s: %w", archive, err))
+					continue
+				}
+
+				c.logger().Debug("Extracted nested archive", "path", archive, "files", files)
+				next = append(next, target)
+			}
+		}
+		dirs = next
+	}
+
+	return extracted, newPartialError(errs)
+}
+
+// findZips returns the zip files below dir
+func findZips(dir string) ([]string, error) {
+	var archives []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() && strings.EqualFold(filepath.Ext(path), ".zip") {
+			archives = append(archives, path)
+		}
+		return nil
+	})
+
+	return archives, err
+}
+
+// extractBounded extracts the archive into outputDir like extractZip, but stops with ErrNestedTooLarge after
+// writing budget bytes, which is decreased by what was written
+func (c *Client) extractBounded(ctx context.Context, zipPath, outputDir string, budget *int64) (int, error) {
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer zipReader.Close()
+
+	extracted := 0
+	for _, file := range zipReader.File {
+		if err := ctx.Err(); err != nil {
+			return extracted, err
+		}
+
+		outputPath, err := c.localPath(outputDir, file.Name)
+		if err != nil {
+			return extracted, err
+		}
+
+		if file.FileInfo().IsDir() {
+			if err = os.MkdirAll(outputPath, entryMode(file, defaultDirMode)|0700); err != nil {
+				return extracted, err
+			}
+			continue
+		}
+
+		// The sizes in the archive may lie, so the budget is enforced on what is actually written as well
+		if file.UncompressedSize64 > uint64(*budget) {
+			return extracted, ErrNestedTooLarge
+		}
+
+		if err = os.MkdirAll(filepath.Dir(outputPath), defaultDirMode); err != nil {
+			return extracted, err
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return extracted, err
+		}
+		err = writeStream(outputPath, &budgetReader{r: rc, budget: budget}, entryMode(file, defaultFileMode), nil)
+		rc.Close()
+		if err != nil {
+			return extracted, err
+		}
+
+		c.setModTime(outputPath, file.Modified)
+		extracted++
+	}
+
+	return extracted, nil
+}
+
+// budgetReader fails with ErrNestedTooLarge once more than budget bytes were read
+type budgetReader struct {
+	r      io.Reader
+	budget *int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	*b.budget -= int64(n)
+	if *b.budget < 0 {
+		return n, ErrNestedTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,41 @@
+package seafile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractNestedKeepsExistingDirectories(t *testing.T) {
+	dir := t.TempDir()
+	for name, entry := range map[string]zipEntry{
+		"photos.zip": {name: "keep.txt", body: "from the archive"},
+		"music.zip":  {name: "song.txt", body: "song"},
+	} {
+		if err := os.Rename(writeTestZip(t, entry), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The library already has a directory the first archive would be extracted into
+	keepPath := filepath.Join(dir, "photos", "keep.txt")
+	if err := os.MkdirAll(filepath.Dir(keepPath), defaultDirMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keepPath, []byte("from the library"), defaultFileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := NewClient("").extractNested(context.Background(), dir)
+	if err != nil || files != 1 {
+		t.Fatalf("got %d files and error %v, want only the other archive extracted", files, err)
+	}
+
+	if data, _ := os.ReadFile(keepPath); string(data) != "from the library" {
+		t.Errorf("the existing directory was overwritten: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "music", "song.txt")); string(data) != "song" {
+		t.Errorf("the other archive wasn't extracted: %q", data)
+	}
+}
@@ -0,0 +1,87 @@
+package seafile
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchPath reports whether name, a slash separated path relative to the library root, matches the pattern.
+// Like in .gitignore, a pattern without a slash matches the name of an entry at any depth, e.g. *.tmp. Other
+// patterns are matched against the whole path, where ** matches any number of directories, e.g.
+// node_modules/** or docs/**/*.pdf. The only possible error is path.ErrBadPattern.
+func MatchPath(pattern, name string) (bool, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+	name = strings.Trim(name, "/")
+
+	if !anchored {
+		if pattern == "**" {
+			return true, nil
+		}
+		return path.Match(pattern, path.Base(name))
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// CheckPattern returns path.ErrBadPattern if the pattern is malformed
+func CheckPattern(pattern string) error {
+	for _, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				if ok, err := matchSegments(pattern[1:], name[skip:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(name) == 0 {
+			return false, nil
+		}
+		if ok, err := path.Match(pattern[0], name[0]); !ok || err != nil {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0, nil
+}
+
+// matchAny returns the first of the patterns that name matches
+func matchAny(patterns []string, name string) (string, bool) {
+	for _, pattern := range patterns {
+		if ok, _ := MatchPath(pattern, name); ok {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+// skipEntry reports whether SyncLibrary leaves out the entry at remotePath because of c.FileExclude or
+// c.FileInclude, and the reason why. Include patterns only apply to files, so directories are still walked
+// to find the files they match.
+func (c *Client) skipEntry(remotePath string, isDir bool) (string, bool) {
+	if pattern, ok := matchAny(c.FileExclude, remotePath); ok {
+		return "it matches exclude pattern " + pattern, true
+	}
+
+	if !isDir && len(c.FileInclude) > 0 {
+		if _, ok := matchAny(c.FileInclude, remotePath); !ok {
+			return "it doesn't match any include pattern", true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,44 @@
+package seafile
+
+import "io"
+
+// ProgressReporter is notified while a download is in progress. A total of -1 means the size is unknown.
+type ProgressReporter interface {
+	Progress(name string, done, total int64)
+	Finish(name string, done, total int64)
+}
+
+// FileProgressReporter is implemented by ProgressReporters that also show how many of the files of a library
+// SyncLibrary went through. A total of 0 means the number of files is unknown.
+type FileProgressReporter interface {
+	FileProgress(name string, done, total int)
+	FinishFiles(name string, done, total int)
+}
+
+// NoopProgress is a ProgressReporter that doesn't report anything
+type NoopProgress struct{}
+
+func (NoopProgress) Progress(name string, done, total int64) {}
+func (NoopProgress) Finish(name string, done, total int64)   {}
+
+// fileProgress returns c.Progress if it shows the progress in files
+func (c *Client) fileProgress() (FileProgressReporter, bool) {
+	reporter, ok := c.Progress.(FileProgressReporter)
+	return reporter, ok
+}
+
+// progressReader reports the number of bytes read through it
+type progressReader struct {
+	io.Reader
+	name     string
+	done     int64
+	total    int64
+	reporter ProgressReporter
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.done += int64(n)
+	r.reporter.Progress(r.name, r.done, r.total)
+	return n, err
+}
@@ -0,0 +1,105 @@
+package seafile
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+)
+
+// RestoreLibrary uploads the directory tree under localDir into the root of the library. Files that
+// already exist remotely are handled according to policy; an empty policy means UploadSkip.
+func (c *Client) RestoreLibrary(ctx context.Context, libraryID, localDir string, policy UploadPolicy) error {
+	if err := c.checkWritable(ctx, libraryID); err != nil {
+		return err
+	}
+
+	// Remote contents per directory; the server renames rather than rejects duplicate directories,
+	// so directories are only created after checking they don't exist already
+	remote := make(map[string]map[string]DirEntry)
+	var uploaded, skipped, failed int
+
+	err := filepath.WalkDir(localDir, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		remotePath := path.Join("/", filepath.ToSlash(rel))
+		parent, name := path.Split(remotePath)
+		parent = path.Clean(parent)
+
+		if d.IsDir() {
+			if remotePath != "/" {
+				if _, exists := remote[parent][name]; !exists {
+					if err := c.MakeDir(ctx, libraryID, remotePath, true); err != nil {
+						return fmt.Errorf("unable to create directory %s: %w", remotePath, err)
+					}
+					remote[remotePath] = make(map[string]DirEntry)
+					return nil
+				}
+			}
+
+			entries, err := c.ListDirectory(ctx, libraryID, remotePath)
+			if err != nil {
+				return fmt.Errorf("unable to list directory %s: %w", remotePath, err)
+			}
+
+			remote[remotePath] = make(map[string]DirEntry, len(entries))
+			for _, entry := range entries {
+				remote[remotePath][entry.Name] = entry
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			c.logger().Info("Skipping, not a regular file", "path", localPath)
+			return nil
+		}
+
+		existing, exists := remote[parent][name]
+		if exists && existing.IsDir() {
+			c.logger().Warn("Unable to restore, a directory with that name exists", "path", remotePath)
+			failed++
+			return nil
+		}
+		if exists && (policy == UploadSkip || policy == "") {
+			skipped++
+			return nil
+		}
+
+		if _, err := c.restoreFile(ctx, libraryID, localPath, parent, exists && policy == UploadOverwrite); err != nil {
+			c.logger().Warn("Unable to restore", "path", remotePath, "error", err)
+			failed++
+			return nil
+		}
+		uploaded++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.logger().Info("Restored directory", "path", localDir, "uploaded", uploaded, "skipped", skipped)
+	if failed > 0 {
+		return fmt.Errorf("%d files failed to restore", failed)
+	}
+
+	return nil
+}
+
+func (c *Client) restoreFile(ctx context.Context, libraryID, localPath, remoteDir string, replace bool) (string, error) {
+	link, err := c.RequestUploadLink(ctx, libraryID, remoteDir)
+	if err != nil {
+		return "", fmt.Errorf("unable to request upload link: %w", err)
+	}
+
+	return c.postFile(ctx, link, localPath, remoteDir, replace)
+}
@@ -0,0 +1,246 @@
+package seafile
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrIncompleteDownload is returned when the server sent less than the announced Content-Length
+	ErrIncompleteDownload = errors.New("download incomplete")
+	// ErrCorruptDownload is returned when a download doesn't match the checksum the server sent along
+	ErrCorruptDownload = errors.New("download corrupt")
+)
+
+// partialDownload is stored next to an interrupted download, so a later run can check that it resumes
+// the same object
+type partialDownload struct {
+	ETag string `json:"etag,omitempty"`
+	Size int64  `json:"size"`
+}
+
+func partialStatePath(partPath string) string {
+	return partPath + ".json"
+}
+
+// loadPartial returns the state of an earlier, interrupted download to partPath and the number of bytes
+// already downloaded. A partial file without usable state can't be resumed and is removed.
+func loadPartial(partPath string) (partialDownload, int64) {
+	var state partialDownload
+
+	info, err := os.Stat(partPath)
+	if err != nil {
+		return state, 0
+	}
+
+	data, err := ioutil.ReadFile(partialStatePath(partPath))
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil || info.Size() == 0 || (state.Size > 0 && info.Size() >= state.Size) {
+		removePartial(partPath)
+		return partialDownload{}, 0
+	}
+
+	return state, info.Size()
+}
+
+func savePartial(partPath string, state partialDownload) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(partialStatePath(partPath), data, defaultFileMode)
+}
+
+func removePartial(partPath string) {
+	os.Remove(partPath)
+	os.Remove(partialStatePath(partPath))
+}
+
+// parseContentRange parses a "bytes start-end/size" header; size is -1 when the server sent "*"
+func parseContentRange(value string) (start, size int64, err error) {
+	spec := strings.TrimPrefix(value, "bytes ")
+	slash := strings.Index(spec, "/")
+	dash := strings.Index(spec, "-")
+	if spec == value || slash < 0 || dash < 0 || dash > slash {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+
+	start, err = strconv.ParseInt(spec[:dash], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+
+	if spec[slash+1:] == "*" {
+		return start, -1, nil
+	}
+
+	size, err = strconv.ParseInt(spec[slash+1:], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+
+	return start, size, nil
+}
+
+// resumes reports whether a 206 response continues the partial download at offset described by state
+func resumes(resp *http.Response, state partialDownload, offset int64) bool {
+	start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil || start != offset {
+		return false
+	}
+
+	if state.Size > 0 && size >= 0 && size != state.Size {
+		return false
+	}
+
+	etag := resp.Header.Get("ETag")
+	return state.ETag == "" || etag == "" || etag == state.ETag
+}
+
+// downloadResumable downloads downloadLink to partPath. If an earlier download to partPath was interrupted
+// and the server supports range requests, only the remainder is requested. The returned count is the
+// number of bytes transferred by this call. An interrupted download is kept for the next attempt.
+func (c *Client) downloadResumable(ctx context.Context, downloadLink, partPath, name string) (int64, error) {
+	state, offset := loadPartial(partPath)
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", downloadLink, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			if len(state.ETag) > 0 {
+				req.Header.Set("If-Range", state.ETag)
+			}
+		}
+
+		resp, err := c.doTransfer(req)
+		if err != nil {
+			return 0, err
+		}
+
+		if err = checkStatus(resp, http.StatusOK, http.StatusPartialContent); err != nil {
+			resp.Body.Close()
+			return 0, err
+		}
+
+		if resp.StatusCode == http.StatusPartialContent && (offset == 0 || !resumes(resp, state, offset)) {
+			// Not the object we downloaded before, so start over with a plain request
+			resp.Body.Close()
+			c.logger().Info("Partial download does not match, restarting", "library", name)
+			removePartial(partPath)
+			state, offset = partialDownload{}, 0
+			continue
+		}
+
+		defer resp.Body.Close()
+		return c.writePartial(resp, partPath, name, offset)
+	}
+}
+
+func (c *Client) writePartial(resp *http.Response, partPath, name string, offset int64) (int64, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	total := resp.ContentLength
+
+	if resp.StatusCode == http.StatusPartialContent {
+		c.logger().Info("Resuming download", "library", name, "offset", offset)
+		flags = os.O_WRONLY | os.O_APPEND
+		if total >= 0 {
+			total += offset
+		}
+	} else {
+		offset = 0
+		removePartial(partPath)
+	}
+
+	resumable := resp.StatusCode == http.StatusPartialContent || resp.Header.Get("Accept-Ranges") == "bytes"
+	if resumable {
+		err := savePartial(partPath, partialDownload{ETag: resp.Header.Get("ETag"), Size: total})
+		if err != nil {
+			c.logger().Warn("Unable to store download state, downloads can't be resumed", "error", err)
+			resumable = false
+		}
+	}
+
+	file, err := os.OpenFile(partPath, flags, defaultFileMode)
+	if err != nil {
+		return 0, err
+	}
+
+	body := &progressReader{Reader: c.throttle(resp.Request.Context(), resp.Body), name: name, done: offset, total: total, reporter: c.Progress}
+	n, err := io.Copy(file, body)
+	c.Progress.Finish(body.name, body.done, body.total)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	// net/http reports a body shorter than its Content-Length as unexpected EOF
+	if (err == nil || errors.Is(err, io.ErrUnexpectedEOF)) && total >= 0 && body.done != total {
+		err = fmt.Errorf("%w: got %d of %d bytes", ErrIncompleteDownload, body.done, total)
+	}
+
+	if err != nil {
+		if !resumable {
+			removePartial(partPath)
+		}
+		return n, err
+	}
+
+	os.Remove(partialStatePath(partPath))
+
+	if err = verifyETag(partPath, resp.Header.Get("ETag")); err != nil {
+		// Resuming a corrupt download would not fix it
+		removePartial(partPath)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// verifyETag compares the MD5 of the file with the ETag, for servers that use the MD5 of the content as ETag.
+// Any other ETag can't be verified and is ignored.
+func verifyETag(path, etag string) error {
+	if strings.HasPrefix(etag, "W/") {
+		return nil
+	}
+
+	etag = strings.ToLower(strings.Trim(etag, "\""))
+	if len(etag) != hex.EncodedLen(md5.Size) {
+		return nil
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != etag {
+		return fmt.Errorf("%w: MD5 %s does not match ETag %s", ErrCorruptDownload, sum, etag)
+	}
+
+	return nil
+}
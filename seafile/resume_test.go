@@ -0,0 +1,70 @@
+package seafile
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestDownloadResumableDetectsBrokenDownloads(t *testing.T) {
+	const content = "the content of the archive"
+
+	tests := []struct {
+		name    string
+		length  int
+		sent    string
+		etag    string
+		wantErr error
+	}{
+		{"complete", len(content), content, "", nil},
+		{"complete with matching MD5", len(content), content, `"` + md5Hex(content) + `"`, nil},
+		{"truncated", len(content), content[:10], "", ErrIncompleteDownload},
+		{"corrupt", len(content), "THE CONTENT OF THE ARCHIVE", `"` + md5Hex(content) + `"`, ErrCorruptDownload},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(test.length))
+				if len(test.etag) > 0 {
+					w.Header().Set("ETag", test.etag)
+				}
+				// net/http closes the connection when the handler writes less than the Content-Length
+				w.Write([]byte(test.sent))
+			}))
+			defer server.Close()
+
+			partPath := filepath.Join(t.TempDir(), "archive.zip.part")
+			n, err := NewClient(server.URL+"/api2").downloadResumable(context.Background(), server.URL, partPath, "library")
+
+			if test.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if data, _ := os.ReadFile(partPath); string(data) != content {
+					t.Errorf("downloaded %q, want %q", data, content)
+				}
+				return
+			}
+
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v after %d bytes, want %v", err, n, test.wantErr)
+			}
+			if _, err := os.Stat(partPath); !os.IsNotExist(err) {
+				t.Errorf("the broken download wasn't removed")
+			}
+		})
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
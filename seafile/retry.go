@@ -0,0 +1,252 @@
+package seafile
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	maxRetryDelay = time.Minute
+	// minRateLimit is the lowest request rate slowDown goes to, in requests per second
+	minRateLimit = rate.Limit(0.1)
+)
+
+// doWithRetry performs the request, retrying connection errors, 5xx and 429 responses up to c.MaxRetries
+// times with exponential backoff (see shouldRetry for requests that aren't idempotent). Other responses
+// (including 401/403/404) are returned immediately.
+func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
+	return c.retry(c.HTTPClient, req)
+}
+
+// doTransfer is doWithRetry for requests that download or upload file contents
+func (c *Client) doTransfer(req *http.Request) (*http.Response, error) {
+	return c.retry(c.transferClient(), req)
+}
+
+// retry performs the request with retries (see retryTransient). When the token it was sent with has expired,
+// a new one is requested through c.Reauthenticate and the request is sent once more with it.
+func (c *Client) retry(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := c.retryTransient(httpClient, req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.Reauthenticate == nil {
+		return resp, err
+	}
+
+	sentToken, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Token ")
+	if !ok || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+
+	// The response holds a connection slot until it is closed, which authenticating again needs with a single
+	// slot. Its body is kept, so the 401 can still be returned if that fails.
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	token, authErr := c.refreshToken(req.Context(), sentToken)
+	if authErr != nil {
+		c.logger().Warn("Unable to renew the expired auth token", "error", authErr)
+		return resp, err
+	}
+
+	retryReq := req.Clone(req.Context())
+	retryReq.Header.Set("Authorization", "Token "+token)
+	if req.GetBody != nil {
+		if retryReq.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only once: the new token being refused as well can't be fixed by yet another one
+	return c.retryTransient(httpClient, retryReq)
+}
+
+// retryTransient performs the request, retrying connection errors, 5xx and 429 responses as shouldRetry allows
+func (c *Client) retryTransient(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	maxRetries := c.MaxRetries
+
+	// A body that cannot be rewound can only be sent once
+	if req.Body != nil && req.GetBody == nil {
+		maxRetries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		release, err := c.acquireConnection(req.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		// Whether the server may have acted on the request, which the error alone doesn't tell
+		var sent atomic.Bool
+		attemptReq = attemptReq.WithContext(httptrace.WithClientTrace(attemptReq.Context(), &httptrace.ClientTrace{
+			WroteRequest: func(info httptrace.WroteRequestInfo) {
+				if info.Err == nil {
+					sent.Store(true)
+				}
+			},
+		}))
+
+		start := time.Now()
+		resp, err := httpClient.Do(attemptReq)
+		if err != nil {
+			release()
+		} else {
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+		}
+		if err != nil {
+			c.logger().Debug("Request failed", "method", req.Method, "url", req.URL.Redacted(), "attempt", attempt+1,
+				"duration", time.Since(start), "error", err)
+		} else {
+			c.logger().Debug("Request done", "method", req.Method, "url", req.URL.Redacted(), "attempt", attempt+1,
+				"status", resp.StatusCode, "duration", time.Since(start))
+		}
+		if attempt >= maxRetries || !shouldRetry(req, resp, err, sent.Load()) {
+			return resp, err
+		}
+
+		delay := backoff(c.RetryDelay, attempt)
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			c.slowDown()
+		}
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = retryAfter
+			}
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// acquireConnection waits for a free slot in c.Connections. The returned function frees it again.
+func (c *Client) acquireConnection(ctx context.Context) (func(), error) {
+	if c.Connections == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case c.Connections <- struct{}{}:
+		return func() { <-c.Connections }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releasingBody frees the connection slot of a response once its body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// slowDown halves the request rate after the server complained about too many requests
+func (c *Client) slowDown() {
+	if c.RateLimiter == nil {
+		return
+	}
+
+	limit := c.RateLimiter.Limit() / 2
+	if limit < minRateLimit {
+		limit = minRateLimit
+	}
+	c.RateLimiter.SetLimit(limit)
+	c.logger().Info("Server is rate limiting, slowing down", "requests_per_second", float64(limit))
+}
+
+// shouldRetry reports whether a failed attempt is worth another one. Requests that aren't idempotent, like
+// the POST that creates a library, are only sent again when the server can't have acted on them: they never
+// reached it, or it answered 429 or 503. Otherwise a timeout after the server already created the library
+// would create a second one.
+func shouldRetry(req *http.Request, resp *http.Response, err error, sent bool) bool {
+	if err != nil {
+		// A cancelled request won't succeed the next time either
+		return req.Context().Err() == nil && (idempotent(req.Method) || !sent)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return true
+	case resp.StatusCode >= 500:
+		return idempotent(req.Method)
+	default:
+		return false
+	}
+}
+
+// idempotent reports whether sending a request with method twice has the same effect as sending it once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the exponential delay for the given attempt, with up to 50% random jitter added.
+// The delay starts at baseDelay and doubles for every following attempt.
+func backoff(baseDelay time.Duration, attempt int) time.Duration {
+	delay := baseDelay << uint(attempt)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// parseRetryAfter understands both forms of the Retry-After header: delay-seconds and an HTTP-date. The
+// delay is capped at maxRetryDelay, so a server asking for hours doesn't stall the whole run.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, maxRetryDelay), true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return min(max(time.Until(date), 0), maxRetryDelay), true
+	}
+
+	return 0, false
+}
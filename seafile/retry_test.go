@@ -0,0 +1,182 @@
+package seafile
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "5", 5 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"negative", "-1", 0, false},
+		{"a day", "86400", maxRetryDelay, true},
+		{"date in the past", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+		{"date far ahead", time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat), maxRetryDelay, true},
+		{"garbage", "soon", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(test.value)
+			if got != test.want || ok != test.ok {
+				t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", test.value, got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestRetryRenewsExpiredToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		renewErr    error
+		wantErr     bool
+		wantRenewed bool
+	}{
+		{"renewed", nil, false, true},
+		{"renewal fails", errors.New("server unreachable"), true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/api2/auth-token/":
+					w.Write([]byte(`{"token": "renewed"}`))
+				case r.Header.Get("Authorization") == "Token renewed":
+					w.Write([]byte("[]"))
+				default:
+					http.Error(w, `{"detail": "Invalid token"}`, http.StatusUnauthorized)
+				}
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL + "/api2")
+			client.Token = "expired"
+			// Renewing the token needs the only connection slot, while the 401 was received in it
+			client.Connections = make(chan struct{}, 1)
+			client.Reauthenticate = func(ctx context.Context) (string, error) {
+				if test.renewErr != nil {
+					return "", test.renewErr
+				}
+				return client.GetToken(ctx, "me@example.com", "password", "")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			body, err := client.getBody(ctx, pathLibraries)
+
+			if errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("renewing the token deadlocked on the connection slot")
+			}
+			if test.wantErr {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Invalid token" {
+					t.Errorf("expected the 401 of the server, got %v", err)
+				}
+			} else if err != nil || string(body) != "[]" {
+				t.Errorf("got %q and error %v, want the response to the renewed token", body, err)
+			}
+			if renewed := client.currentToken() == "renewed"; renewed != test.wantRenewed {
+				t.Errorf("token renewed: %v, want %v", renewed, test.wantRenewed)
+			}
+			if len(client.Connections) != 0 {
+				t.Errorf("%d connection slots are still taken", len(client.Connections))
+			}
+		})
+	}
+}
+
+func TestRetryOnlyRepeatsWhatIsSafeToRepeat(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		status       int
+		dropResponse bool
+		wantAttempts int
+	}{
+		{"GET on 500", http.MethodGet, http.StatusInternalServerError, false, 3},
+		{"POST on 500", http.MethodPost, http.StatusInternalServerError, false, 1},
+		{"POST on 503", http.MethodPost, http.StatusServiceUnavailable, false, 3},
+		{"POST on 429", http.MethodPost, http.StatusTooManyRequests, false, 3},
+		{"PUT without a response", http.MethodPut, 0, true, 3},
+		{"POST without a response", http.MethodPost, 0, true, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				if test.dropResponse {
+					// The request arrived, but the client can't tell whether it was acted on
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err == nil {
+						conn.Close()
+					}
+					return
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL + "/api2")
+			client.MaxRetries = 2
+			client.RetryDelay = time.Millisecond
+
+			req, err := http.NewRequest(test.method, server.URL+"/api2/repos/", strings.NewReader("name=Photos"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.doWithRetry(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if got := int(attempts.Load()); got != test.wantAttempts {
+				t.Errorf("sent %d times, want %d", got, test.wantAttempts)
+			}
+		})
+	}
+}
+
+// failingTransport fails every request before it is sent
+type failingTransport struct {
+	attempts int
+}
+
+func (t *failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.attempts++
+	return nil, errors.New("connection refused")
+}
+
+func TestRetryRepeatsPostsThatNeverLeft(t *testing.T) {
+	transport := &failingTransport{}
+	client := NewClient("https://seafile.example.com/api2")
+	client.HTTPClient = &http.Client{Transport: transport}
+	client.MaxRetries = 2
+	client.RetryDelay = time.Millisecond
+
+	req, err := http.NewRequest(http.MethodPost, "https://seafile.example.com/api2/repos/", strings.NewReader("name=Photos"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.doWithRetry(req); err == nil {
+		t.Fatal("expected an error")
+	}
+	if transport.attempts != 3 {
+		t.Errorf("sent %d times, want 3", transport.attempts)
+	}
+}
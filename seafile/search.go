@@ -0,0 +1,99 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pathSearch = "/search/"
+	// searchPageSize is the number of results requested per page
+	searchPageSize = 100
+)
+
+// ErrSearchUnavailable is returned when the server has no search index; search is only available on
+// Seafile Professional with indexing enabled
+var ErrSearchUnavailable = errors.New("search is not enabled on this server")
+
+// SearchResult is a file or directory matching a search query
+type SearchResult struct {
+	LibraryId   string `json:"repo_id"`
+	LibraryName string `json:"repo_name"`
+	Name        string `json:"name"`
+	Path        string `json:"fullpath"`
+	Size        int64  `json:"size"`
+	Mtime       int64  `json:"last_modified"`
+	Dir         bool   `json:"is_dir"`
+	// Snippet is the part of the content matching the query, with the matches in <b> tags
+	Snippet string `json:"content_highlight"`
+}
+
+// ModTime returns the modification time of the file
+func (r SearchResult) ModTime() time.Time {
+	return time.Unix(r.Mtime, 0)
+}
+
+// SearchFiles searches all libraries of the account for files and directories matching query
+func (c *Client) SearchFiles(ctx context.Context, query string) ([]SearchResult, error) {
+	var results []SearchResult
+
+	if c.Server != nil && !c.Server.HasFeature(FeatureSearch) {
+		return nil, fmt.Errorf("%w: the server doesn't list the %s feature", ErrSearchUnavailable, FeatureSearch)
+	}
+
+	for page := 1; ; page++ {
+		params := url.Values{}
+		params.Set("q", query)
+		params.Set("search_repo", "all")
+		params.Set("page", strconv.Itoa(page))
+		params.Set("per_page", strconv.Itoa(searchPageSize))
+
+		req, err := c.newRequest(ctx, "GET", pathSearch+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.doWithRetry(req)
+		if err != nil {
+			return nil, err
+		}
+
+		bodyBinary, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			apiErr := newAPIError(resp, bodyBinary)
+			// Servers without an index answer "Search not supported" (404 on the community edition)
+			if resp.StatusCode == http.StatusNotFound || strings.Contains(strings.ToLower(apiErr.Message), "not supported") {
+				return nil, fmt.Errorf("%w: %v", ErrSearchUnavailable, apiErr)
+			}
+			return nil, apiErr
+		}
+
+		var found struct {
+			Total   int            `json:"total"`
+			HasMore bool           `json:"has_more"`
+			Results []SearchResult `json:"results"`
+		}
+		err = json.Unmarshal(bodyBinary, &found)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, found.Results...)
+		if !found.HasMore || len(found.Results) == 0 {
+			return results, nil
+		}
+	}
+}
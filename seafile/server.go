@@ -0,0 +1,56 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+const pathServerInfo = "/server-info/"
+
+// Features a server can report in ServerInfo
+const (
+	FeaturePro    = "seafile-pro"
+	FeatureSearch = "file-search"
+)
+
+// ServerInfo describes the Seafile server: its version and the optional features it has enabled
+type ServerInfo struct {
+	Version  string   `json:"version"`
+	Features []string `json:"features"`
+}
+
+// HasFeature reports whether the server lists the feature
+func (s ServerInfo) HasFeature(feature string) bool {
+	return slices.Contains(s.Features, feature)
+}
+
+// GetServerInfo requests the version and features of the server, which needs no authentication, and keeps
+// them in c.Server so later requests can adapt to the server
+func (c *Client) GetServerInfo(ctx context.Context) (ServerInfo, error) {
+	var info ServerInfo
+
+	req, err := c.newRequest(ctx, "GET", pathServerInfo, nil)
+	if err != nil {
+		return info, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return info, err
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return info, fmt.Errorf("unreadable server info: %w", err)
+	}
+
+	c.Server = &info
+	return info, nil
+}
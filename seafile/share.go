@@ -0,0 +1,182 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const pathShareLinks = apiV21Path + "/share-links/"
+
+// SharePermission determines what visitors of a share link may do
+type SharePermission string
+
+const (
+	// ShareView only allows viewing in the browser
+	ShareView SharePermission = "view"
+	// ShareDownload also allows downloading
+	ShareDownload SharePermission = "download"
+)
+
+// ShareOptions are the optional settings of a new share link
+type ShareOptions struct {
+	Password string
+	// ExpireDays is the number of days the link is valid; zero means it doesn't expire
+	ExpireDays int
+	// Permission defaults to ShareDownload
+	Permission SharePermission
+}
+
+// ShareLink is a public link to a file or directory
+type ShareLink struct {
+	Token       string `json:"token"`
+	Link        string `json:"link"`
+	LibraryId   string `json:"repo_id"`
+	LibraryName string `json:"repo_name"`
+	Path        string `json:"path"`
+	ViewCount   int    `json:"view_cnt"`
+	// Expires is zero for links that don't expire
+	Expires time.Time `json:"-"`
+}
+
+// UnmarshalJSON also parses expire_date, which is empty for links that don't expire
+func (l *ShareLink) UnmarshalJSON(data []byte) error {
+	type link ShareLink
+	raw := struct {
+		*link
+		ExpireDate string `json:"expire_date"`
+	}{link: (*link)(l)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if len(raw.ExpireDate) > 0 {
+		expires, err := time.Parse(time.RFC3339, raw.ExpireDate)
+		if err != nil {
+			return fmt.Errorf("invalid expire_date of share link %s: %w", l.Token, err)
+		}
+		l.Expires = expires
+	}
+
+	return nil
+}
+
+// Expired reports whether the link stopped working
+func (l ShareLink) Expired() bool {
+	return !l.Expires.IsZero() && l.Expires.Before(time.Now())
+}
+
+// ListShareLinks returns all share links created by the account
+func (c *Client) ListShareLinks(ctx context.Context) ([]ShareLink, error) {
+	req, err := c.newRequest(ctx, "GET", pathShareLinks, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, bodyBinary)
+	}
+
+	var links []ShareLink
+	err = json.Unmarshal(bodyBinary, &links)
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// DeleteShareLink revokes the share link with the given token
+func (c *Client) DeleteShareLink(ctx context.Context, token string) error {
+	req, err := c.newRequest(ctx, "DELETE", pathShareLinks+url.PathEscape(token)+"/", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp, http.StatusOK, http.StatusNoContent)
+}
+
+// CreateShareLink creates a public link to the file or directory at filePath and returns its URL
+func (c *Client) CreateShareLink(ctx context.Context, libraryID, filePath string, opts ShareOptions) (string, error) {
+	if opts.ExpireDays < 0 {
+		return "", errors.New("the number of days until the share link expires can't be negative")
+	}
+
+	permission := opts.Permission
+	if len(permission) == 0 {
+		permission = ShareDownload
+	}
+	if permission != ShareView && permission != ShareDownload {
+		return "", errors.New("share link permission must be view or download")
+	}
+
+	type permissions struct {
+		CanEdit     bool `json:"can_edit"`
+		CanDownload bool `json:"can_download"`
+	}
+
+	request := struct {
+		LibraryId   string      `json:"repo_id"`
+		Path        string      `json:"path"`
+		Password    string      `json:"password,omitempty"`
+		ExpireDays  int         `json:"expire_days,omitempty"`
+		Permissions permissions `json:"permissions"`
+	}{
+		LibraryId:   libraryID,
+		Path:        filePath,
+		Password:    opts.Password,
+		ExpireDays:  opts.ExpireDays,
+		Permissions: permissions{CanDownload: permission == ShareDownload},
+	}
+
+	req, err := c.newJSONRequest(ctx, "POST", pathShareLinks, request)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", newAPIError(resp, bodyBinary)
+	}
+
+	var link ShareLink
+	err = json.Unmarshal(bodyBinary, &link)
+	if err != nil {
+		return "", err
+	}
+
+	return link.Link, nil
+}
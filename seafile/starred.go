@@ -0,0 +1,58 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const pathStarredFiles = "/starredfiles/"
+
+// StarredFile is a file (or directory) the account has starred, in any library
+type StarredFile struct {
+	LibraryId string `json:"repo"`
+	// LibraryName is only sent by newer servers
+	LibraryName string `json:"repo_name"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Mtime       int64  `json:"mtime"`
+	Dir         bool   `json:"dir"`
+}
+
+// ModTime returns the modification time of the file
+func (f StarredFile) ModTime() time.Time {
+	return time.Unix(f.Mtime, 0)
+}
+
+// ListStarredFiles returns the starred files of the account across all libraries
+func (c *Client) ListStarredFiles(ctx context.Context) ([]StarredFile, error) {
+	req, err := c.newRequest(ctx, "GET", pathStarredFiles, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, bodyBinary)
+	}
+
+	var files []StarredFile
+	err = json.Unmarshal(bodyBinary, &files)
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
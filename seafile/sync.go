@@ -0,0 +1,269 @@
+package seafile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrTooManyDeletions is returned when mirroring would delete more than c.MaxDelete local entries
+var ErrTooManyDeletions = errors.New("too many local files to delete")
+
+// countWorkers is the number of directories listed in parallel when counting the files of a library
+const countWorkers = 4
+
+// SyncLibrary walks the library and only downloads files into outputDir that are missing locally, or
+// whose size or modification time differs from the server's. Small files whose content still matches
+// are kept. With c.DeleteExtraneous, local entries that don't exist in the library are removed afterwards.
+func (c *Client) SyncLibrary(ctx context.Context, library Library, outputDir string) (Stats, error) {
+	var (
+		pending = []string{"/"}
+		stats   Stats
+		errs    []error
+		// expected are the local paths of all entries of the library
+		expected = map[string]bool{outputDir: true}
+		// skipped are the local paths of the directories left out, whose contents weren't looked at
+		skipped = make(map[string]bool)
+		// checked is the number of files gone through, of total
+		checked, total int
+	)
+
+	progress, showProgress := c.fileProgress()
+	if showProgress {
+		total = c.fileCount(ctx, library)
+		defer func() { progress.FinishFiles(library.Name, checked, total) }()
+	}
+
+	for len(pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		dirPath := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		entries, err := c.ListDirectory(ctx, library.Id, dirPath)
+		if err != nil {
+			return stats, fmt.Errorf("unable to list %s: %w", dirPath, err)
+		}
+
+		for _, entry := range entries {
+			remotePath := path.Join(dirPath, entry.Name)
+			localPath, err := c.localPath(outputDir, remotePath)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			expected[localPath] = true
+
+			if reason, skip := c.skipEntry(remotePath, entry.IsDir()); skip {
+				c.logger().Debug("Skipping, "+reason, "library", library.Name, "path", remotePath)
+				if entry.IsDir() {
+					skipped[localPath] = true
+				}
+				continue
+			}
+
+			if entry.IsDir() {
+				if err = os.MkdirAll(localPath, defaultDirMode); err != nil {
+					errs = append(errs, fmt.Errorf("unable to create directory %s: %w", remotePath, err))
+					continue
+				}
+				pending = append(pending, remotePath)
+				continue
+			}
+
+			checked++
+			if showProgress {
+				progress.FileProgress(library.Name, checked, total)
+			}
+
+			if c.MaxFileSize > 0 && entry.Size > c.MaxFileSize {
+				c.logger().Info("Skipping file larger than the maximum size", "library", library.Name, "path", remotePath,
+					"size", entry.Size, "max_size", c.MaxFileSize)
+				continue
+			}
+
+			if upToDate(localPath, entry) {
+				continue
+			}
+
+			// Only the mtime differs, e.g. because the file was copied without preserving it
+			if sameSize(localPath, entry) && contentMatches(localPath, entry, library.Version) {
+				c.setModTime(localPath, entry.ModTime())
+				continue
+			}
+
+			err = c.syncFile(ctx, library, remotePath, localPath, entry)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to download %s: %w", remotePath, err))
+				continue
+			}
+			stats.Files++
+			stats.Bytes += entry.Size
+		}
+	}
+
+	// An entry without a local path may still exist locally under another name, so nothing is deleted then
+	if c.DeleteExtraneous && len(errs) == 0 {
+		deleted, err := c.removeExtraneous(outputDir, expected, skipped)
+		stats.Deleted = deleted
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return stats, newPartialError(errs)
+}
+
+// fileCount returns the number of files in the library as reported by the server or, with c.CountFiles,
+// counted upfront; 0 when it isn't known
+func (c *Client) fileCount(ctx context.Context, library Library) int {
+	if library.FileCount > 0 {
+		return library.FileCount
+	}
+
+	// The list of libraries doesn't include it, their details do on some servers
+	if details, err := c.GetLibrary(ctx, library.Id); err == nil && details.FileCount > 0 {
+		return details.FileCount
+	}
+
+	if !c.CountFiles {
+		return 0
+	}
+
+	root, err := c.WalkTree(ctx, library.Id, "/", 0, countWorkers)
+	if err != nil {
+		c.logger().Warn("Unable to count the files of the library", "library", library.Name, "error", err)
+		return 0
+	}
+
+	count := 0
+	for pending := []*TreeNode{root}; len(pending) > 0; {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, child := range node.Children {
+			if child.IsDir() {
+				pending = append(pending, child)
+			} else {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// removeExtraneous removes everything below outputDir that isn't expected, or only logs it with c.DryRun.
+// The skipped directories are left alone as a whole. Nothing is removed when that would be more than
+// c.MaxDelete entries.
+func (c *Client) removeExtraneous(outputDir string, expected, skipped map[string]bool) (int, error) {
+	var (
+		removals []string
+		count    int
+	)
+
+	err := filepath.WalkDir(outputDir, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skipped[localPath] {
+			return filepath.SkipDir
+		}
+		if expected[localPath] || strings.HasSuffix(d.Name(), TempSuffix) {
+			return nil
+		}
+
+		// WalkDir doesn't follow symlinks, but never touch anything outside of the library's directory
+		rel, err := filepath.Rel(outputDir, localPath)
+		if err == nil {
+			_, err = safeJoin(outputDir, rel)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Entries that are left out weren't looked at, so they may well still exist in the library
+		if _, skip := c.skipEntry(filepath.ToSlash(rel), d.IsDir()); skip {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		removals = append(removals, localPath)
+		if !d.IsDir() {
+			count++
+			return nil
+		}
+
+		// The directory is removed as a whole, but everything in it counts towards c.MaxDelete
+		err = filepath.WalkDir(localPath, func(_ string, _ fs.DirEntry, err error) error {
+			if err == nil {
+				count++
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to find local files to delete: %w", err)
+	}
+
+	if c.MaxDelete > 0 && count > c.MaxDelete {
+		return 0, fmt.Errorf("%w: %d entries, at most %d allowed", ErrTooManyDeletions, count, c.MaxDelete)
+	}
+
+	deleted := 0
+	for _, localPath := range removals {
+		if c.DryRun {
+			c.logger().Info("Would delete, it doesn't exist in the library anymore", "path", localPath)
+			continue
+		}
+
+		if err = os.RemoveAll(localPath); err != nil {
+			return deleted, fmt.Errorf("unable to delete %s: %w", localPath, err)
+		}
+		c.logger().Info("Deleted, it doesn't exist in the library anymore", "path", localPath)
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+func (c *Client) syncFile(ctx context.Context, library Library, remotePath, localPath string, entry DirEntry) error {
+	link, err := c.RequestFileLink(ctx, library.Id, remotePath)
+	if err != nil {
+		return err
+	}
+
+	err = c.downloadToFile(ctx, link, localPath)
+	if err != nil {
+		return err
+	}
+
+	// Without the server's mtime the file would look changed on the next run
+	return os.Chtimes(localPath, entry.ModTime(), entry.ModTime())
+}
+
+func upToDate(localPath string, entry DirEntry) bool {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return false
+	}
+
+	return info.Mode().IsRegular() && info.Size() == entry.Size && info.ModTime().Unix() == entry.Mtime
+}
+
+func sameSize(localPath string, entry DirEntry) bool {
+	info, err := os.Stat(localPath)
+	return err == nil && info.Mode().IsRegular() && info.Size() == entry.Size
+}
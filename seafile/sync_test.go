@@ -0,0 +1,153 @@
+package seafile
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.tmp", "docs/draft.tmp", true},
+		{"*.tmp", "docs/draft.txt", false},
+		{"node_modules", "node_modules", true},
+		{"node_modules", "src/node_modules", true},
+		{"node_modules", "node_modules/keep.js", false},
+		{"node_modules/**", "node_modules/lib/keep.js", true},
+		{"docs/**/*.pdf", "docs/report.pdf", true},
+		{"docs/**/*.pdf", "docs/2026/q1/report.pdf", true},
+		{"docs/*.pdf", "old/docs/report.pdf", false},
+		{"/docs/", "/docs", true},
+	}
+
+	for _, test := range tests {
+		got, err := MatchPath(test.pattern, test.name)
+		if err != nil {
+			t.Errorf("MatchPath(%q, %q): %v", test.pattern, test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", test.pattern, test.name, got, test.want)
+		}
+	}
+}
+
+func TestSkipEntry(t *testing.T) {
+	c := NewClient("")
+	c.FileInclude = []string{"*.pdf"}
+	c.FileExclude = []string{"drafts"}
+
+	tests := []struct {
+		remotePath string
+		isDir      bool
+		want       bool
+	}{
+		{"/report.pdf", false, false},
+		{"/report.txt", false, true},
+		// Include patterns don't apply to directories, the files in them may still match
+		{"/docs", true, false},
+		{"/drafts", true, true},
+		{"/docs/drafts/report.pdf", false, false},
+		{"/drafts/report.pdf", false, false},
+	}
+
+	for _, test := range tests {
+		if _, got := c.skipEntry(test.remotePath, test.isDir); got != test.want {
+			t.Errorf("skipEntry(%q, %v) = %v, want %v", test.remotePath, test.isDir, got, test.want)
+		}
+	}
+}
+
+func TestSyncLibraryMirrorKeepsLeftOutEntries(t *testing.T) {
+	modified := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		include     []string
+		exclude     []string
+		wantDeleted int
+		// wantKept are the local files that don't exist in the library but must survive
+		wantKept []string
+	}{
+		{"no patterns", nil, nil, 3, nil},
+		{"excluded directory", nil, []string{"node_modules"}, 2, []string{"node_modules/keep.js"}},
+		{"not included", []string{"*.txt"}, nil, 1, []string{"node_modules/keep.js", "notes.md"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api2/repos/lib-1/dir/" {
+					t.Errorf("unexpected request %s", r.URL.RequestURI())
+					http.NotFound(w, r)
+					return
+				}
+
+				switch r.URL.Query().Get("p") {
+				case "/":
+					fmt.Fprintf(w, `[{"type": "dir", "name": "node_modules"}, {"type": "file", "name": "report.txt", "size": 6, "mtime": %d}]`,
+						modified.Unix())
+				case "/node_modules":
+					if len(test.exclude) > 0 {
+						t.Errorf("the excluded directory was listed")
+					}
+					w.Write([]byte(`[]`))
+				default:
+					t.Errorf("unexpected listing of %s", r.URL.Query().Get("p"))
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			outputDir := t.TempDir()
+			for name, content := range map[string]string{
+				"report.txt":           "report",
+				"stale.txt":            "stale",
+				"notes.md":             "notes",
+				"node_modules/keep.js": "keep",
+			} {
+				path := filepath.Join(outputDir, name)
+				if err := os.MkdirAll(filepath.Dir(path), defaultDirMode); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), defaultFileMode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Up to date, so nothing is downloaded
+			if err := os.Chtimes(filepath.Join(outputDir, "report.txt"), modified, modified); err != nil {
+				t.Fatal(err)
+			}
+
+			client := NewClient(server.URL + "/api2")
+			client.FileInclude = test.include
+			client.FileExclude = test.exclude
+			client.DeleteExtraneous = true
+
+			stats, err := client.SyncLibrary(context.Background(), Library{Id: "lib-1", Name: "Library"}, outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Files != 0 || stats.Deleted != test.wantDeleted {
+				t.Errorf("downloaded %d and deleted %d files, want 0 and %d", stats.Files, stats.Deleted, test.wantDeleted)
+			}
+
+			for _, name := range append([]string{"report.txt"}, test.wantKept...) {
+				if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+					t.Errorf("%s was deleted: %v", name, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(outputDir, "stale.txt")); !os.IsNotExist(err) {
+				t.Errorf("stale.txt wasn't deleted")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package seafile
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// throttledReader limits reads to the rate of a limiter counting bytes. As the limiter is shared, all
+// downloads together stay within the limit.
+type throttledReader struct {
+	io.Reader
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// WaitN fails for more than the burst, so never read more at once
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+// throttle returns r limited by c.BandwidthLimiter, if set
+func (c *Client) throttle(ctx context.Context, r io.Reader) io.Reader {
+	if c.BandwidthLimiter == nil {
+		return r
+	}
+
+	return &throttledReader{Reader: r, ctx: ctx, limiter: c.BandwidthLimiter}
+}
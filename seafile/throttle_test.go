@@ -0,0 +1,57 @@
+package seafile
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestThrottleLimitsAllDownloadsTogether(t *testing.T) {
+	const (
+		limit   = 200 * 1000
+		burst   = 10 * 1000
+		streams = 2
+		size    = 50 * 1000
+	)
+	client := NewClient("")
+	client.BandwidthLimiter = rate.NewLimiter(rate.Limit(limit), burst)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := io.Copy(io.Discard, client.throttle(context.Background(), bytes.NewReader(make([]byte, size))))
+			if err != nil || n != size {
+				t.Errorf("read %d bytes with error %v, want %d", n, err, size)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// The burst is available right away, the rest arrives at the limit
+	want := time.Duration(float64(streams*size-burst) / limit * float64(time.Second))
+	if elapsed < want*9/10 || elapsed > want*3/2 {
+		t.Errorf("reading %d bytes took %v, want about %v", streams*size, elapsed, want)
+	}
+}
+
+func TestThrottleStopsWithTheContext(t *testing.T) {
+	client := NewClient("")
+	client.BandwidthLimiter = rate.NewLimiter(rate.Limit(1000), 1000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := io.Copy(io.Discard, client.throttle(ctx, bytes.NewReader(make([]byte, 100*1000))))
+	if err == nil {
+		t.Errorf("expected the throttled read to stop when the context ends")
+	}
+}
@@ -0,0 +1,62 @@
+package seafile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const pathThumbnail = "/thumbnail/"
+
+// ErrNotAnImage is returned when requesting the thumbnail of a file the server can't make one of
+var ErrNotAnImage = errors.New("not an image")
+
+// thumbnailTypes are the extensions of the files Seafile generates thumbnails for
+var thumbnailTypes = map[string]bool{
+	".bmp": true, ".gif": true, ".jpeg": true, ".jpg": true, ".png": true, ".tif": true, ".tiff": true, ".webp": true,
+}
+
+// Thumbnail returns a size×size (at most) thumbnail of the image at filePath, as an encoded image
+func (c *Client) Thumbnail(ctx context.Context, libraryID, filePath string, size int) ([]byte, error) {
+	if !thumbnailTypes[strings.ToLower(path.Ext(filePath))] {
+		return nil, fmt.Errorf("%s: %w", filePath, ErrNotAnImage)
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid thumbnail size %d", size)
+	}
+
+	query := url.Values{}
+	query.Set("p", filePath)
+	query.Set("size", strconv.Itoa(size))
+
+	req, err := c.newRequest(ctx, "GET", pathLibraries+libraryID+pathThumbnail+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusBadRequest {
+		return nil, fmt.Errorf("%s: %w: %v", filePath, ErrNotAnImage, newAPIError(resp, bodyBinary))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, bodyBinary)
+	}
+
+	return bodyBinary, nil
+}
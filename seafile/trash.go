@@ -0,0 +1,127 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+)
+
+const (
+	pathTrash      = "/trash/"
+	pathFileRevert = "/file/revert/"
+	pathDirRevert  = "/dir/revert/"
+)
+
+// TrashEntry is a deleted file or directory in the trash of a library
+type TrashEntry struct {
+	Name      string    `json:"obj_name"`
+	ParentDir string    `json:"parent_dir"`
+	CommitId  string    `json:"commit_id"`
+	IsDir     bool      `json:"is_dir"`
+	Size      int64     `json:"size"`
+	Deleted   time.Time `json:"-"`
+}
+
+// Path returns the path the entry had before it was deleted
+func (e TrashEntry) Path() string {
+	return path.Join("/", e.ParentDir, e.Name)
+}
+
+// UnmarshalJSON also parses deleted_time, which servers send as either a timestamp or an RFC 3339 string
+func (e *TrashEntry) UnmarshalJSON(data []byte) error {
+	type entry TrashEntry
+	raw := struct {
+		*entry
+		DeletedTime json.RawMessage `json:"deleted_time"`
+	}{entry: (*entry)(e)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var deleted string
+	if err := json.Unmarshal(raw.DeletedTime, &deleted); err != nil {
+		deleted = string(raw.DeletedTime)
+	}
+
+	if seconds, err := strconv.ParseInt(deleted, 10, 64); err == nil {
+		e.Deleted = time.Unix(seconds, 0)
+	} else if t, err := time.Parse(time.RFC3339, deleted); err == nil {
+		e.Deleted = t
+	}
+
+	return nil
+}
+
+// ListTrash returns the deleted files and directories of the library
+func (c *Client) ListTrash(ctx context.Context, libraryID string) ([]TrashEntry, error) {
+	query := url.Values{}
+	query.Set("path", "/")
+
+	req, err := c.newRequest(ctx, "GET", pathLibraries+libraryID+pathTrash+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrLibraryNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, bodyBinary)
+	}
+
+	var trash struct {
+		Data []TrashEntry `json:"data"`
+	}
+	err = json.Unmarshal(bodyBinary, &trash)
+	if err != nil {
+		return nil, err
+	}
+
+	return trash.Data, nil
+}
+
+// RestoreFromTrash restores the entry to its original path by reverting it to the commit it was deleted in
+func (c *Client) RestoreFromTrash(ctx context.Context, libraryID string, entry TrashEntry) error {
+	revertPath := pathFileRevert
+	if entry.IsDir {
+		revertPath = pathDirRevert
+	}
+
+	data := url.Values{}
+	data.Set("p", entry.Path())
+	data.Set("commit_id", entry.CommitId)
+
+	req, err := c.newFormRequest(ctx, "PUT", pathLibraries+libraryID+revertPath, data)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("unable to restore %s: %w", entry.Path(), err)
+	}
+
+	return nil
+}
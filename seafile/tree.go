@@ -0,0 +1,119 @@
+package seafile
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+)
+
+// TreeNode is a file or directory in a tree returned by WalkTree
+type TreeNode struct {
+	DirEntry
+	// Path is the path of the entry within the library
+	Path     string
+	Children []*TreeNode
+	// Size is the size of a file, or the total size of everything below a directory that was walked
+	Size int64
+	// Truncated is set for directories at the depth limit, whose contents weren't listed
+	Truncated bool
+
+	parent *TreeNode
+}
+
+// treeWalk is the state shared by the workers of WalkTree. Directories still to be listed are queued rather
+// than recursed into, so deep trees don't grow the stack.
+type treeWalk struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*treeNodeDepth
+	pending int
+	nodes   []*TreeNode
+	err     error
+}
+
+type treeNodeDepth struct {
+	node  *TreeNode
+	depth int
+}
+
+// WalkTree lists dirPath of the library and everything below it, down to maxDepth levels (zero means no
+// limit), with workers directories being listed in parallel. Directory sizes are summed up from their contents.
+func (c *Client) WalkTree(ctx context.Context, libraryID, dirPath string, maxDepth, workers int) (*TreeNode, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	root := &TreeNode{DirEntry: DirEntry{Type: EntryTypeDir, Name: path.Base(dirPath)}, Path: dirPath}
+	walk := &treeWalk{queue: []*treeNodeDepth{{node: root}}, pending: 1, nodes: []*TreeNode{root}}
+	walk.cond = sync.NewCond(&walk.mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.treeWorker(ctx, libraryID, maxDepth, walk)
+		}()
+	}
+	wg.Wait()
+
+	if walk.err != nil {
+		return nil, walk.err
+	}
+
+	// Children are always created after their parent, so going backwards adds every size before it's needed
+	for i := len(walk.nodes) - 1; i > 0; i-- {
+		node := walk.nodes[i]
+		node.parent.Size += node.Size
+	}
+
+	return root, nil
+}
+
+func (c *Client) treeWorker(ctx context.Context, libraryID string, maxDepth int, walk *treeWalk) {
+	walk.mu.Lock()
+	defer walk.mu.Unlock()
+
+	for {
+		for len(walk.queue) == 0 && walk.pending > 0 && walk.err == nil {
+			walk.cond.Wait()
+		}
+		if walk.pending == 0 || walk.err != nil {
+			return
+		}
+
+		job := walk.queue[len(walk.queue)-1]
+		walk.queue = walk.queue[:len(walk.queue)-1]
+
+		walk.mu.Unlock()
+		entries, err := c.ListDirectory(ctx, libraryID, job.node.Path)
+		walk.mu.Lock()
+
+		if err != nil {
+			if walk.err == nil {
+				walk.err = fmt.Errorf("unable to list %s: %w", job.node.Path, err)
+			}
+			walk.cond.Broadcast()
+			return
+		}
+
+		for _, entry := range entries {
+			child := &TreeNode{DirEntry: entry, Path: path.Join(job.node.Path, entry.Name), parent: job.node}
+			if !entry.IsDir() {
+				child.Size = entry.Size
+			} else if maxDepth > 0 && job.depth+1 >= maxDepth {
+				child.Truncated = true
+			} else {
+				walk.queue = append(walk.queue, &treeNodeDepth{node: child, depth: job.depth + 1})
+				walk.pending++
+			}
+
+			job.node.Children = append(job.node.Children, child)
+			walk.nodes = append(walk.nodes, child)
+		}
+
+		walk.pending--
+		walk.cond.Broadcast()
+	}
+}
@@ -0,0 +1,184 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const pathUploadLink = "/upload-link/"
+
+// UploadPolicy decides what happens when uploading a file that already exists in the target directory
+type UploadPolicy string
+
+const (
+	// UploadSkip leaves the existing file alone and doesn't upload
+	UploadSkip UploadPolicy = "skip"
+	// UploadOverwrite replaces the existing file, keeping the old contents in the file history
+	UploadOverwrite UploadPolicy = "overwrite"
+	// UploadRename lets the server store the upload under a new name, e.g. "report (1).pdf"
+	UploadRename UploadPolicy = "rename"
+)
+
+// ErrFileExists is returned when a file isn't uploaded with UploadSkip because it exists already
+var ErrFileExists = errors.New("file exists already")
+
+// RequestUploadLink returns a link to which files for remoteDir of the library can be posted
+func (c *Client) RequestUploadLink(ctx context.Context, libraryID, remoteDir string) (string, error) {
+	query := url.Values{}
+	query.Set("p", remoteDir)
+
+	return c.getLink(ctx, pathLibraries+libraryID+pathUploadLink+"?"+query.Encode())
+}
+
+// UploadFile uploads localPath into remoteDir of the library and returns the server's response,
+// a JSON description (name, id and size) of the uploaded file. An existing file of the same name is handled
+// according to policy; with UploadSkip (or an empty policy), ErrFileExists is returned.
+func (c *Client) UploadFile(ctx context.Context, libraryID, localPath, remoteDir string, policy UploadPolicy) (string, error) {
+	if err := c.checkWritable(ctx, libraryID); err != nil {
+		return "", err
+	}
+
+	if policy == UploadSkip || policy == "" {
+		entries, err := c.ListDirectory(ctx, libraryID, remoteDir)
+		if err != nil {
+			return "", fmt.Errorf("unable to list %s: %w", remoteDir, err)
+		}
+
+		name := filepath.Base(localPath)
+		for _, entry := range entries {
+			if entry.Name == name {
+				return "", fmt.Errorf("%s: %w", path.Join(remoteDir, name), ErrFileExists)
+			}
+		}
+	}
+
+	link, err := c.RequestUploadLink(ctx, libraryID, remoteDir)
+	if err != nil {
+		return "", fmt.Errorf("unable to request upload link: %w", err)
+	}
+
+	return c.postFile(ctx, link, localPath, remoteDir, policy == UploadOverwrite)
+}
+
+// postFile sends localPath to an upload link as multipart form, streaming it rather than buffering it.
+// With replace set, an existing file of the same name is overwritten instead of the upload being renamed.
+func (c *Client) postFile(ctx context.Context, link, localPath, remoteDir string, replace bool) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	bodyReader, bodyWriter := io.Pipe()
+	form := multipart.NewWriter(bodyWriter)
+
+	go func() {
+		err := form.WriteField("parent_dir", remoteDir)
+		if err == nil && replace {
+			err = form.WriteField("replace", "1")
+		}
+		if err == nil {
+			var part io.Writer
+			part, err = form.CreateFormFile("file", filepath.Base(localPath))
+			if err == nil {
+				_, err = io.Copy(part, file)
+			}
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		bodyWriter.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", link+"?ret-json=1", bodyReader)
+	if err != nil {
+		bodyReader.Close()
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := c.doTransfer(req)
+	if err != nil {
+		bodyReader.Close()
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp, bodyBinary)
+	}
+
+	return string(bodyBinary), nil
+}
+
+// UploadViaLink uploads localPath into parentDir (relative to the shared directory) through a public upload
+// link such as https://seafile.example.com/u/d/0123456789/. No token is needed: the server first hands out
+// the URL of its upload server for the link, to which the file is then posted.
+func (c *Client) UploadViaLink(ctx context.Context, uploadLinkURL, localPath, parentDir string) (string, error) {
+	u, err := url.Parse(uploadLinkURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid upload link: %w", err)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-3] != "u" || parts[len(parts)-2] != "d" {
+		return "", fmt.Errorf("invalid upload link %q, expected https://server/u/d/<token>/", uploadLinkURL)
+	}
+	token := parts[len(parts)-1]
+
+	// The API lives next to the /u/d/ path, which may be below a prefix
+	u.Path = "/" + strings.Join(parts[:len(parts)-3], "/")
+	endpoint, err := url.JoinPath(u.String(), apiV21Path, "upload-links", token, "upload/")
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	bodyBinary, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp, bodyBinary)
+	}
+
+	var link struct {
+		UploadLink string `json:"upload_link"`
+	}
+	if err = json.Unmarshal(bodyBinary, &link); err != nil {
+		return "", err
+	}
+	if len(link.UploadLink) == 0 {
+		return "", fmt.Errorf("server did not return an upload server for %s", uploadLinkURL)
+	}
+
+	return c.postFile(ctx, c.fileServerLink(link.UploadLink), localPath, parentDir, false)
+}
@@ -0,0 +1,100 @@
+package seafile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	pathZipProgress = apiV21Path + "/query-zip-progress/"
+
+	zipPollDelay    = 500 * time.Millisecond
+	zipPollMaxDelay = 5 * time.Second
+	// zipLogInterval limits how often the progress of a zip being prepared is logged
+	zipLogInterval = 10 * time.Second
+)
+
+// ErrZipFailed is returned when the server gives up preparing a zip
+var ErrZipFailed = errors.New("server failed to prepare the zip")
+
+// zipTask is the answer of servers that prepare a zip in the background instead of handing out a link right away
+type zipTask struct {
+	Token string `json:"zip_token"`
+}
+
+// zipProgress is the state of a zip task, in files
+type zipProgress struct {
+	Zipped       int    `json:"zipped"`
+	Total        int    `json:"total"`
+	Failed       int    `json:"failed"`
+	FailedReason string `json:"failed_reason"`
+}
+
+// waitForZip polls the progress of the zip task with the given token, backing off up to zipPollMaxDelay
+// between polls, and returns the link to download the zip from once it is ready
+func (c *Client) waitForZip(ctx context.Context, token, name string) (string, error) {
+	delay := zipPollDelay
+	var logged time.Time
+
+	for {
+		progress, err := c.zipProgress(ctx, token)
+		if err != nil {
+			return "", fmt.Errorf("unable to query zip progress: %w", err)
+		}
+
+		if progress.Failed > 0 || len(progress.FailedReason) > 0 {
+			return "", fmt.Errorf("%w: %s", ErrZipFailed, progress.FailedReason)
+		}
+
+		if progress.Zipped >= progress.Total {
+			c.logger().Debug("Zip prepared", "library", name, "files", progress.Total)
+			return c.zipLink(token), nil
+		}
+
+		if time.Since(logged) >= zipLogInterval {
+			c.logger().Info("Waiting for the server to prepare the zip", "library", name, "zipped", progress.Zipped, "total", progress.Total)
+			logged = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > zipPollMaxDelay {
+			delay = zipPollMaxDelay
+		}
+	}
+}
+
+func (c *Client) zipProgress(ctx context.Context, token string) (zipProgress, error) {
+	query := url.Values{}
+	query.Set("token", token)
+
+	var progress zipProgress
+	bodyBinary, err := c.getBody(ctx, pathZipProgress+"?"+query.Encode())
+	if err != nil {
+		return progress, err
+	}
+
+	err = json.Unmarshal(bodyBinary, &progress)
+	return progress, err
+}
+
+// zipLink returns the link a prepared zip is served at by the file server: below c.FileServerURL if set, and
+// otherwise below seafhttp on the server itself, where a default installation has it
+func (c *Client) zipLink(token string) string {
+	root := c.FileServerURL
+	if len(root) == 0 {
+		root = strings.TrimSuffix(strings.TrimRight(c.BaseURL, "/"), apiPath) + "/seafhttp"
+	}
+
+	return strings.TrimSuffix(root, "/") + "/zip/" + url.PathEscape(token)
+}